@@ -32,33 +32,7 @@ func main() {
 				Usage:     "build the static html content",
 				ArgsUsage: "[src-dir]",
 				Action:    cmd.Build,
-				Flags: []cli.Flag{
-					&cli.StringFlag{
-						Name:        "dist",
-						Usage:       "output directory",
-						DefaultText: "<src>/dist",
-					},
-					&cli.StringFlag{
-						Name:        "theme",
-						Usage:       "the theme to use",
-						DefaultText: "<src>/.theme",
-					},
-					&cli.StringFlag{
-						Name:    "author",
-						Usage:   "global post author",
-						EnvVars: []string{"DOKTRI_AUTHOR"},
-					},
-					&cli.StringFlag{
-						Name:    "context",
-						Usage:   "context path used when generating links",
-						EnvVars: []string{"DOKTRI_CONTEXT"},
-					},
-					&cli.StringFlag{
-						Name:    "chroma-style",
-						Usage:   "chroma style to use for syntax highlighting",
-						EnvVars: []string{"DOKTRI_CHROMA_STYLE"},
-					},
-				},
+				Flags:     engineFlags(),
 			},
 			{
 				Name:      "serve",
@@ -66,33 +40,19 @@ func main() {
 				Usage:     "build and serve the static html content, with hot reload",
 				ArgsUsage: "[src-dir]",
 				Action:    cmd.Serve,
-				Flags: []cli.Flag{
-					&cli.StringFlag{
-						Name:        "dist",
-						Usage:       "output directory",
-						DefaultText: "<src>/dist",
-					},
-					&cli.StringFlag{
-						Name:        "theme",
-						Usage:       "the theme to use",
-						DefaultText: "<src>/.theme",
-					},
-					&cli.StringFlag{
-						Name:    "author",
-						Usage:   "global post author",
-						EnvVars: []string{"DOKTRI_AUTHOR"},
-					},
+				Flags: append(engineFlags(),
 					&cli.IntFlag{
-						Name:  "port",
-						Usage: "the port to listen on",
-						Value: 3000,
+						Name:        "port",
+						Usage:       "the port to listen on",
+						EnvVars:     []string{"DOKTRI_PORT"},
+						DefaultText: "3000",
 					},
-					&cli.StringFlag{
-						Name:    "chroma-style",
-						Usage:   "chroma style to use for syntax highlighting",
-						EnvVars: []string{"DOKTRI_CHROMA_STYLE"},
+					&cli.BoolFlag{
+						Name:    "drafts",
+						Usage:   "include drafts and scheduled posts",
+						EnvVars: []string{"DOKTRI_DRAFTS"},
 					},
-				},
+				),
 			},
 			{
 				Name:      "init",
@@ -107,6 +67,11 @@ func main() {
 						Usage:   "title to set in the meta.yaml",
 						Value:   "My Page",
 					},
+					&cli.StringFlag{
+						Name:  "theme",
+						Usage: "path to a local theme dir, or name of a bundled theme",
+						Value: "default",
+					},
 				},
 			},
 			{
@@ -132,3 +97,107 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// the flags shared by all commands that run the engine. Each of them can also
+// be set in the doktri.yaml of the source dir. Flags and environment take
+// precedence over the file
+func engineFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "profile",
+			Aliases: []string{"p"},
+			Usage:   "profile of the doktri.yaml to apply",
+			EnvVars: []string{"DOKTRI_PROFILE"},
+		},
+		&cli.StringFlag{
+			Name:        "dist",
+			Usage:       "output directory",
+			EnvVars:     []string{"DOKTRI_DIST"},
+			DefaultText: "<src>/dist",
+		},
+		&cli.StringFlag{
+			Name:        "theme",
+			Usage:       "theme dir, archive or name of a bundled theme",
+			EnvVars:     []string{"DOKTRI_THEME"},
+			DefaultText: "<src>/.theme or default",
+		},
+		&cli.StringFlag{
+			Name:    "author",
+			Usage:   "global post author",
+			EnvVars: []string{"DOKTRI_AUTHOR"},
+		},
+		&cli.StringFlag{
+			Name:    "context",
+			Usage:   "context path used when generating links",
+			EnvVars: []string{"DOKTRI_CONTEXT"},
+		},
+		&cli.StringFlag{
+			Name:    "chroma-style",
+			Usage:   "chroma style to use for syntax highlighting",
+			EnvVars: []string{"DOKTRI_CHROMA_STYLE"},
+		},
+		&cli.BoolFlag{
+			Name:  "clean",
+			Usage: "ignore the build manifest and render everything",
+		},
+		&cli.IntFlag{
+			Name:        "jobs",
+			Aliases:     []string{"j"},
+			Usage:       "number of pages to render concurrently",
+			EnvVars:     []string{"DOKTRI_JOBS"},
+			DefaultText: "number of cpus",
+		},
+		&cli.StringSliceFlag{
+			Name:        "date-fallback",
+			Usage:       "sources to take the date of undated posts from, tried in order",
+			EnvVars:     []string{"DOKTRI_DATE_FALLBACK"},
+			DefaultText: "frontmatter,git,mtime",
+		},
+		&cli.StringSliceFlag{
+			Name:    "acronyms",
+			Usage:   "words to keep as is, in titles derived from file names, i.e. API,K8s",
+			EnvVars: []string{"DOKTRI_ACRONYMS"},
+		},
+		&cli.BoolFlag{
+			Name:    "frontmatter",
+			Usage:   "let the front matter override title, date, author, layout and params",
+			EnvVars: []string{"DOKTRI_FRONTMATTER"},
+		},
+		&cli.BoolFlag{
+			Name:    "minify-resources",
+			Usage:   "minify the css, js and html files in the docs dir, instead of copying them verbatim",
+			EnvVars: []string{"DOKTRI_MINIFY_RESOURCES"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "ignore",
+			Usage:   "gitignore style patterns of paths in the source dir to ignore, in addition to the .doktriignore",
+			EnvVars: []string{"DOKTRI_IGNORE"},
+		},
+		&cli.StringFlag{
+			Name:    "base-url",
+			Usage:   "the scheme and host the site is served from, i.e. https://example.com. Required for feeds",
+			EnvVars: []string{"DOKTRI_BASE_URL"},
+		},
+		&cli.IntFlag{
+			Name:        "feed-limit",
+			Usage:       "the max number of items per feed",
+			EnvVars:     []string{"DOKTRI_FEED_LIMIT"},
+			DefaultText: "20",
+		},
+		&cli.BoolFlag{
+			Name:    "feed-full-content",
+			Usage:   "put the whole post into the feeds, instead of the excerpt",
+			EnvVars: []string{"DOKTRI_FEED_FULL_CONTENT"},
+		},
+		&cli.BoolFlag{
+			Name:    "section-feeds",
+			Usage:   "generate feeds for every dir, not only the root",
+			EnvVars: []string{"DOKTRI_SECTION_FEEDS"},
+		},
+		&cli.StringFlag{
+			Name:    "robots-txt",
+			Usage:   "the file to use as robots.txt, defaults to the robots.txt in the source dir",
+			EnvVars: []string{"DOKTRI_ROBOTS_TXT"},
+		},
+	}
+}
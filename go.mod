@@ -14,6 +14,7 @@ require (
 	github.com/yuin/goldmark-highlighting/v2 v2.0.0-20230729083705-37449abec8cc
 	go.abhg.dev/goldmark/frontmatter v0.2.0
 	go.abhg.dev/goldmark/toc v0.11.0
+	golang.org/x/sys v0.30.0
 	golang.org/x/text v0.24.0
 	sigs.k8s.io/yaml v1.4.0
 )
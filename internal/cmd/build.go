@@ -3,20 +3,19 @@ package cmd
 import (
 	"fmt"
 
+	"github.com/bluebrown/doktri/internal/config"
 	"github.com/bluebrown/doktri/internal/engine"
 	"github.com/urfave/cli/v2"
 )
 
 func Build(cCtx *cli.Context) error {
-	e := engine.New(
-		engine.WithSource(cCtx.Args().First()),
-		engine.WithDist(cCtx.String("dist")),
-		engine.WithTheme(cCtx.String("theme")),
-		engine.WithAuthor(cCtx.String("author")),
-		engine.WithContextPath(cCtx.String("context")),
-		engine.WithChromaStyle(cCtx.String("chroma-style")),
-	)
-	return build(e)
+	c, err := config.FromContext(cCtx)
+	if err != nil {
+		return err
+	}
+	// drafts are for previews only, and never part of a build
+	c.Drafts = false
+	return build(engine.New(c.Options()...))
 }
 
 func build(e engine.Engine) error {
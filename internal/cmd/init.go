@@ -2,11 +2,12 @@ package cmd
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
-	"os/exec"
 	"path/filepath"
 
 	"github.com/bluebrown/doktri/internal/fsys"
+	"github.com/bluebrown/doktri/internal/theme"
 	"github.com/urfave/cli/v2"
 	"sigs.k8s.io/yaml"
 )
@@ -17,7 +18,11 @@ func InitProject(cCtx *cli.Context) error {
 	if outDir == "" {
 		outDir = "."
 	}
-	return initProject(title, outDir)
+	themeFS, err := theme.Open(cCtx.String("theme"))
+	if err != nil {
+		return err
+	}
+	return initProject(title, outDir, themeFS)
 }
 
 type social struct {
@@ -31,7 +36,7 @@ type defaultMeta struct {
 	Socials []social `json:"socials,omitempty"`
 }
 
-func initProject(title, outDir string) error {
+func initProject(title, outDir string, themeFS fs.FS) error {
 
 	// check if dir is empty
 	ok, err := fsys.PathExists(outDir)
@@ -54,11 +59,12 @@ func initProject(title, outDir string) error {
 	}
 
 	themeDir := filepath.Join(outDir, ".theme")
-	if err := exec.Command("git", "clone", "https://github.com/bluebrown/doktri-theme-default", themeDir).Start(); err != nil {
-		return fmt.Errorf("clone theme to %q: %w", themeDir, err)
+	if err := os.CopyFS(themeDir, themeFS); err != nil {
+		return fmt.Errorf("write theme to %q: %w", themeDir, err)
 	}
 
-	if err := os.RemoveAll(filepath.Join(outDir, ".theme", ".git")); err != nil {
+	// a local theme may be a git checkout
+	if err := os.RemoveAll(filepath.Join(themeDir, ".git")); err != nil {
 		return fmt.Errorf("remove theme .git: %w", err)
 	}
 
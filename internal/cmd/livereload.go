@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// the path of the server-sent events endpoint, the client script connects to
+	liveReloadPath = "/__doktri/livereload"
+	// sent after a successful build, to reload the page
+	eventReload = "reload"
+	// sent after a successful build, if only stylesheets have changed
+	eventCSS = "css"
+)
+
+// the client script is injected into every served html page. On a reload event
+// it reloads the page, on a css event it swaps the stylesheets by changing
+// their href, so the browser fetches them again without a full reload
+const liveReloadScript = `<script>(function(){` +
+	`var es=new EventSource("` + liveReloadPath + `");` +
+	`es.addEventListener("` + eventReload + `",function(){location.reload()});` +
+	`es.addEventListener("` + eventCSS + `",function(){` +
+	`document.querySelectorAll('link[rel="stylesheet"]').forEach(function(l){` +
+	`var u=new URL(l.href);u.searchParams.set("__doktri",Date.now());l.href=u.href})})` +
+	`})()</script>`
+
+// the broker keeps track of the connected browsers and pushes events to them
+type broker struct {
+	mu      sync.Mutex
+	clients map[chan string]struct{}
+}
+
+func newBroker() *broker {
+	return &broker{clients: make(map[chan string]struct{})}
+}
+
+// send the event to all connected clients. Clients that are not ready to
+// receive, miss the event rather than blocking the broadcast
+func (b *broker) Broadcast(event string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for c := range b.clients {
+		select {
+		case c <- event:
+		default:
+		}
+	}
+}
+
+// serve the event stream until the client disconnects
+func (b *broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	c := make(chan string, 1)
+	b.mu.Lock()
+	b.clients[c] = struct{}{}
+	b.mu.Unlock()
+
+	defer func() {
+		b.mu.Lock()
+		delete(b.clients, c)
+		b.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case event := <-c:
+			fmt.Fprintf(w, "event: %s\ndata: {}\n\n", event)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// wrap the handler, to inject the live reload script into html responses
+func injectLiveReload(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// always send the full page, so there is a body to inject into
+		r.Header.Del("If-Modified-Since")
+		r.Header.Del("If-None-Match")
+		r.Header.Del("Range")
+
+		rec := &responseBuffer{header: make(http.Header), status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		body := rec.body.Bytes()
+		if rec.status == http.StatusOK && strings.HasPrefix(rec.header.Get("Content-Type"), "text/html") {
+			body = injectScript(body, liveReloadScript)
+			rec.header.Set("Content-Length", strconv.Itoa(len(body)))
+			rec.header.Set("Cache-Control", "no-store")
+		}
+
+		for k, v := range rec.header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.status)
+		w.Write(body)
+	})
+}
+
+// insert the script before the closing body tag. Since the minifier drops
+// optional closing tags, the script is appended if there is no body tag
+func injectScript(html []byte, script string) []byte {
+	i := bytes.LastIndex(html, []byte("</body>"))
+	if i < 0 {
+		return append(html, script...)
+	}
+	out := make([]byte, 0, len(html)+len(script))
+	out = append(out, html[:i]...)
+	out = append(out, script...)
+	return append(out, html[i:]...)
+}
+
+// buffers a response, so that it can be modified before it is sent
+type responseBuffer struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rb *responseBuffer) Header() http.Header {
+	return rb.header
+}
+
+func (rb *responseBuffer) WriteHeader(status int) {
+	rb.status = status
+}
+
+func (rb *responseBuffer) Write(b []byte) (int, error) {
+	return rb.body.Write(b)
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/bluebrown/doktri/internal/engine"
+)
+
+// the error page is served instead of html pages, while the last build failed.
+// It includes the live reload script, so that it goes away on its own once the
+// next build succeeds
+var errorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>doktri: build failed</title>
+<style>
+body { margin: 0; padding: 2rem; background: #1e1e2e; color: #f8f8f2; font-family: monospace; }
+h1 { color: #ff5555; font-size: 1.5rem; }
+dl { display: grid; grid-template-columns: max-content auto; gap: .25rem 1rem; }
+dt { color: #8be9fd; }
+dd { margin: 0; }
+ol { padding-left: 1.5rem; }
+li { margin-bottom: .5rem; white-space: pre-wrap; word-break: break-word; }
+</style>
+</head>
+<body>
+<h1>Build failed</h1>
+{{- range .Nodes }}
+<dl>
+  <dt>source</dt><dd>{{ .SourcePath }}</dd>
+  {{- if .Template }}
+  <dt>template</dt><dd>{{ .Template }}</dd>
+  <dt>location</dt><dd>{{ .Location }}</dd>
+  {{- end }}
+</dl>
+{{- end }}
+<ol>
+{{- range .Chain }}
+  <li>{{ . }}</li>
+{{- end }}
+</ol>
+{{ .Script }}
+</body>
+</html>
+`))
+
+type errorPageData struct {
+	Nodes  []*engine.RenderError
+	Chain  []string
+	Script template.HTML
+}
+
+// serve the error page for html requests, while the build is failing
+func (s *DevServer) overlayErrors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := s.failure()
+		if err == nil || !(strings.HasSuffix(r.URL.Path, "/") || strings.HasSuffix(r.URL.Path, ".html")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusInternalServerError)
+
+		errorPage.Execute(w, errorPageData{
+			Nodes:  renderErrors(err),
+			Chain:  errorChain(err),
+			Script: template.HTML(liveReloadScript),
+		})
+	})
+}
+
+// collect all render errors in the tree of wrapped errors
+func renderErrors(err error) []*engine.RenderError {
+	var out []*engine.RenderError
+	var visit func(err error)
+	visit = func(err error) {
+		switch e := err.(type) {
+		case nil:
+		case *engine.RenderError:
+			out = append(out, e)
+		case interface{ Unwrap() []error }:
+			for _, e := range e.Unwrap() {
+				visit(e)
+			}
+		default:
+			visit(errors.Unwrap(err))
+		}
+	}
+	visit(err)
+	return out
+}
+
+// unwrap the error step by step, and return the message of each step, without
+// the message of the wrapped error. engine.Errors are flattened into the chain
+func errorChain(err error) []string {
+	var chain []string
+	for err != nil {
+		if ee, ok := err.(engine.Errors); ok {
+			for _, e := range ee {
+				chain = append(chain, errorChain(e)...)
+			}
+			break
+		}
+		msg := err.Error()
+		next := errors.Unwrap(err)
+		if next != nil {
+			msg = strings.TrimSuffix(strings.TrimSuffix(msg, next.Error()), ": ")
+		}
+		// some errors only add context to the type, but not to the message
+		if msg != "" {
+			chain = append(chain, msg)
+		}
+		err = next
+	}
+	return chain
+}
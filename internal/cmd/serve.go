@@ -3,55 +3,94 @@ package cmd
 import (
 	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/bluebrown/doktri/internal/config"
 	"github.com/bluebrown/doktri/internal/engine"
+	"github.com/bluebrown/doktri/internal/fsys"
+	"github.com/bluebrown/doktri/internal/ignore"
 	"github.com/radovskyb/watcher"
 	"github.com/urfave/cli/v2"
 )
 
 type DevServer struct {
-	Source string
-	Dist   string
-	Theme  string
-	Author string
-	Style  string
-	Port   int
+	Config config.Config
 	ngn    engine.Engine
+	events *broker
+	mu     sync.Mutex
+	err    error
+	// the ignore rules of the current engine, to filter watch events
+	ignored *ignore.Matcher
+	// the absolute source dir, to match watched paths against the rules
+	src string
+	// the entries of the source dir that are watched. Everything else in
+	// it, such as the dist dir, is not
+	watched map[string]bool
 }
 
 func Serve(cCtx *cli.Context) error {
-	s := &DevServer{
-		Source: cCtx.Args().First(),
-		Dist:   cCtx.String("dist"),
-		Theme:  cCtx.String("theme"),
-		Author: cCtx.String("author"),
-		Port:   cCtx.Int("port"),
-		Style:  cCtx.String("chroma-style"),
+	c, err := config.FromContext(cCtx)
+	if err != nil {
+		return err
 	}
+	s := &DevServer{Config: c, events: newBroker()}
 	return s.Serve()
 }
 
 func (s *DevServer) Serve() error {
+	src, err := filepath.Abs(s.Config.Source)
+	if err != nil {
+		return fmt.Errorf("source dir: %w", err)
+	}
+	s.src = src
+
 	s.makeEngine()
 	if err := build(s.ngn); err != nil {
-		return fmt.Errorf("render: %w", err)
+		fmt.Printf("render: %v\n", err)
+		s.setFailure(err)
+	}
+
+	s.watched = map[string]bool{}
+	for _, p := range append([]string{
+		s.ngn.DocsDir(), s.ngn.ExtraAssetsDir(), s.ngn.TemplatesDir(),
+		s.ngn.MetaPath(), s.ngn.IgnorePath(),
+	}, s.ngn.ThemeDirs()...) {
+		p, _ = filepath.Abs(p)
+		if rel, err := filepath.Rel(s.src, p); err == nil && filepath.IsLocal(rel) {
+			top, _, _ := strings.Cut(filepath.ToSlash(rel), "/")
+			s.watched[top] = true
+		}
 	}
 
 	w := watcher.New()
-	w.SetMaxEvents(1)
+	w.AddFilterHook(s.skipUnwatched)
+	w.AddFilterHook(s.skipIgnored)
 	errC := make(chan error)
 
 	go func() {
 		for {
 			select {
 			case event := <-w.Event:
-				fmt.Printf("\nchange detected: %s\n", event.Path)
-				s.makeEngine()
-				if err := build(s.ngn); err != nil {
-					fmt.Printf("render: %v\n", err)
+				events := changes(event, w.Event)
+				if len(events) == 0 {
+					continue
 				}
+				fmt.Printf("\nchange detected: %s\n", events[0].Path)
+				s.watchCreated(w, events)
+				s.rebuild(onlyCSS(events))
 			case err := <-w.Error:
+				// a dir that is watched on its own, such as the templates
+				// dir, has been removed. The watcher stops watching it,
+				// without reporting its files as removed
+				if err == watcher.ErrWatchedFileDeleted {
+					fmt.Println("\nchange detected: a watched dir was removed")
+					s.rebuild(false)
+					continue
+				}
 				errC <- fmt.Errorf("watch: %w", err)
 				return
 			case <-w.Closed:
@@ -64,16 +103,26 @@ func (s *DevServer) Serve() error {
 		return fmt.Errorf("watch sources: %w", err)
 	}
 
-	if err := w.AddRecursive(s.ngn.ThemeTemplatesDir()); err != nil {
-		return fmt.Errorf("watch theme templates: %w", err)
+	// only themes in local dirs can change, archives and the bundled
+	// theme are read once
+	for _, dir := range s.ngn.ThemeDirs() {
+		if err := w.AddRecursive(dir); err != nil {
+			return fmt.Errorf("watch theme: %w", err)
+		}
 	}
 
-	if err := w.AddRecursive(s.ngn.ThemeAssetsDir()); err != nil {
-		return fmt.Errorf("watch theme assets: %w", err)
+	// the source dir itself is watched without its subdirs, to notice the
+	// optional dirs, once they are created. This covers the ignore file, too
+	if err := w.Add(s.src); err != nil {
+		return fmt.Errorf("watch source dir: %w", err)
 	}
 
-	if err := w.AddRecursive(s.ngn.ThemeAssetsDir()); err != nil {
-		return fmt.Errorf("watch extra assets: %w", err)
+	for _, dir := range s.optionalDirs() {
+		if ok, _ := fsys.PathExists(dir); ok {
+			if err := w.AddRecursive(dir); err != nil {
+				return fmt.Errorf("watch %s: %w", filepath.Base(dir), err)
+			}
+		}
 	}
 
 	if err := w.Add(s.ngn.MetaPath()); err != nil {
@@ -88,9 +137,11 @@ func (s *DevServer) Serve() error {
 
 	go func() {
 		w.Wait()
-		fmt.Printf("\n- Serving content on http://localhost:%d 📚\n\n", s.Port)
-		http.Handle("/", http.FileServer(http.Dir(s.ngn.DistDir())))
-		if err := http.ListenAndServe(fmt.Sprintf("localhost:%d", s.Port), nil); err != http.ErrServerClosed {
+		fmt.Printf("\n- Serving content on http://localhost:%d 📚\n\n", s.Config.Port)
+		mux := http.NewServeMux()
+		mux.Handle(liveReloadPath, s.events)
+		mux.Handle("/", s.overlayErrors(injectLiveReload(http.FileServer(http.Dir(s.ngn.DistDir())))))
+		if err := http.ListenAndServe(fmt.Sprintf("localhost:%d", s.Config.Port), mux); err != http.ErrServerClosed {
 			errC <- fmt.Errorf("server: %w", err)
 		}
 	}()
@@ -98,12 +149,132 @@ func (s *DevServer) Serve() error {
 	return <-errC
 }
 
+// build the site again and tell the browsers about it. If only stylesheets
+// changed, the browser can swap them without reloading the page
+func (s *DevServer) rebuild(onlyCSS bool) {
+	s.makeEngine()
+	if err := build(s.ngn); err != nil {
+		fmt.Printf("render: %v\n", err)
+		s.setFailure(err)
+		s.events.Broadcast(eventReload)
+		return
+	}
+	// after a failure, the browser shows the error page, so it needs a
+	// full reload
+	if s.setFailure(nil) != nil || !onlyCSS {
+		s.events.Broadcast(eventReload)
+		return
+	}
+	s.events.Broadcast(eventCSS)
+}
+
+// record the error of the last build, nil meaning it succeeded. The
+// previous error is returned
+func (s *DevServer) setFailure(err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev := s.err
+	s.err = err
+	return prev
+}
+
+// the error of the last build, if it failed
+func (s *DevServer) failure() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
 func (s *DevServer) makeEngine() {
-	s.ngn = engine.New(
-		engine.WithSource(s.Source),
-		engine.WithDist(s.Dist),
-		engine.WithTheme(s.Theme),
-		engine.WithAuthor(s.Author),
-		engine.WithChromaStyle(s.Style),
-	)
+	s.ngn = engine.New(s.Config.Options()...)
+	// keep the previous rules, if the ignore file cannot be read. The build
+	// reports the error
+	if m, err := s.ngn.Ignore(); err == nil {
+		s.mu.Lock()
+		s.ignored = m
+		s.mu.Unlock()
+	}
+}
+
+// collect the events that belong to the same change as the first one, and
+// return the ones that require a rebuild. Writes to dirs are dropped, since
+// they only signal that entries have been added or removed, which is reported
+// for the entries themselves, unless they are ignored
+func changes(first watcher.Event, more <-chan watcher.Event) []watcher.Event {
+	var events []watcher.Event
+	for event, ok := first, true; ok; {
+		if !(event.IsDir() && event.Op == watcher.Write) {
+			events = append(events, event)
+		}
+		select {
+		case event = <-more:
+		case <-time.After(50 * time.Millisecond):
+			ok = false
+		}
+	}
+	return events
+}
+
+// report whether all events are about stylesheets
+func onlyCSS(events []watcher.Event) bool {
+	for _, event := range events {
+		if !strings.HasSuffix(event.Path, ".css") {
+			return false
+		}
+	}
+	return true
+}
+
+// the dirs of the source dir, that may not exist, when serve starts
+func (s *DevServer) optionalDirs() []string {
+	return []string{
+		filepath.Join(s.src, filepath.Base(s.ngn.TemplatesDir())),
+		filepath.Join(s.src, filepath.Base(s.ngn.ExtraAssetsDir())),
+	}
+}
+
+// watch the optional dirs that have been created since serve started
+func (s *DevServer) watchCreated(w *watcher.Watcher, events []watcher.Event) {
+	for _, event := range events {
+		if !event.IsDir() || !(event.Op == watcher.Create || event.Op == watcher.Rename || event.Op == watcher.Move) {
+			continue
+		}
+		for _, dir := range s.optionalDirs() {
+			if event.Path != dir {
+				continue
+			}
+			if err := w.AddRecursive(dir); err != nil {
+				fmt.Printf("watch %s: %v\n", filepath.Base(dir), err)
+			}
+		}
+	}
+}
+
+// the watcher hook skips the entries of the source dir, that don't affect the
+// site, such as the dist dir
+func (s *DevServer) skipUnwatched(info os.FileInfo, fullPath string) error {
+	if filepath.Dir(fullPath) == s.src && !s.watched[filepath.Base(fullPath)] {
+		return watcher.ErrSkip
+	}
+	return nil
+}
+
+// the watcher hook skips paths in the docs and assets dir that are ignored, so
+// that changing them does not trigger a rebuild
+func (s *DevServer) skipIgnored(info os.FileInfo, fullPath string) error {
+	rel, err := filepath.Rel(s.src, fullPath)
+	if err != nil || !filepath.IsLocal(rel) {
+		return nil
+	}
+	rel = filepath.ToSlash(rel)
+	if top, _, _ := strings.Cut(rel, "/"); top != "docs" && top != "assets" {
+		return nil
+	}
+	s.mu.Lock()
+	m := s.ignored
+	s.mu.Unlock()
+	if m.Match(rel, info.IsDir()) {
+		return watcher.ErrSkip
+	}
+	return nil
 }
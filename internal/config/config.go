@@ -0,0 +1,354 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"sigs.k8s.io/yaml"
+
+	"github.com/bluebrown/doktri/internal/engine"
+)
+
+// the name of the config file, relative to the source dir
+const FileName = "doktri.yaml"
+
+// the settings shared by all commands. The keys are the same as the names of
+// the corresponding command line flags
+type Config struct {
+	// the source dir. This is not read from the file, since the file is looked
+	// up in the source dir
+	Source      string `json:"-"`
+	Dist        string `json:"dist,omitempty"`
+	Theme       string `json:"theme,omitempty"`
+	Author      string `json:"author,omitempty"`
+	Context     string `json:"context,omitempty"`
+	ChromaStyle string `json:"chroma-style,omitempty"`
+	Clean       bool   `json:"clean,omitempty"`
+	Jobs        int    `json:"jobs,omitempty"`
+	Port        int    `json:"port,omitempty"`
+	// include drafts and scheduled posts. This is only honored by serve
+	Drafts bool `json:"drafts,omitempty"`
+	// the sources to take the date of undated posts from, tried in order
+	DateFallback []string `json:"date-fallback,omitempty"`
+	// words to keep as is, in titles derived from file names
+	Acronyms []string `json:"acronyms,omitempty"`
+	// let the front matter override the values derived from the file names
+	FrontMatter bool `json:"frontmatter,omitempty"`
+	// minify the resources in the docs dir, instead of copying them verbatim
+	MinifyResources bool `json:"minify-resources,omitempty"`
+	// gitignore style patterns of paths in the source dir to ignore, in
+	// addition to the ones of the .doktriignore
+	Ignore []string `json:"ignore,omitempty"`
+	// the scheme and host the site is served from, for absolute urls
+	BaseURL string `json:"base-url,omitempty"`
+	// the max number of items per feed
+	FeedLimit int `json:"feed-limit,omitempty"`
+	// put the whole post into the feeds, instead of the excerpt
+	FeedFullContent bool `json:"feed-full-content,omitempty"`
+	// generate feeds for every dir, not only the root
+	SectionFeeds bool `json:"section-feeds,omitempty"`
+	// the file to use as robots.txt, instead of the robots.txt in the source
+	// dir
+	RobotsTxt string `json:"robots-txt,omitempty"`
+}
+
+// the content of the config file. The top level settings apply to all
+// profiles, and the settings of the selected profile are applied on top
+type File struct {
+	Settings
+	Profiles map[string]Settings `json:"profiles,omitempty"`
+}
+
+// the settings as read from the config file. Unlike in Config, settings that
+// are not in the file are nil, so that a profile can set a setting back to its
+// zero value, i.e. clean: false
+type Settings struct {
+	Dist            *string  `json:"dist,omitempty"`
+	Theme           *string  `json:"theme,omitempty"`
+	Author          *string  `json:"author,omitempty"`
+	Context         *string  `json:"context,omitempty"`
+	ChromaStyle     *string  `json:"chroma-style,omitempty"`
+	Clean           *bool    `json:"clean,omitempty"`
+	Jobs            *int     `json:"jobs,omitempty"`
+	Port            *int     `json:"port,omitempty"`
+	Drafts          *bool    `json:"drafts,omitempty"`
+	DateFallback    []string `json:"date-fallback,omitempty"`
+	Acronyms        []string `json:"acronyms,omitempty"`
+	FrontMatter     *bool    `json:"frontmatter,omitempty"`
+	MinifyResources *bool    `json:"minify-resources,omitempty"`
+	Ignore          []string `json:"ignore,omitempty"`
+	BaseURL         *string  `json:"base-url,omitempty"`
+	FeedLimit       *int     `json:"feed-limit,omitempty"`
+	FeedFullContent *bool    `json:"feed-full-content,omitempty"`
+	SectionFeeds    *bool    `json:"section-feeds,omitempty"`
+	RobotsTxt       *string  `json:"robots-txt,omitempty"`
+}
+
+// the defaults are applied for settings that have been set neither by the
+// command line, the environment nor the config file
+func Defaults() Config {
+	return Config{
+		Port: 3000,
+	}
+}
+
+// load the config for the source dir with the given profile. Settings are
+// applied in the order defaults, config file, profile. If there is no config
+// file, the defaults are returned. Paths in the file are relative to the
+// source dir
+func Load(src, profile string) (Config, error) {
+	if src == "" {
+		src = "."
+	}
+
+	c := Defaults()
+	c.Source = src
+
+	b, err := os.ReadFile(filepath.Join(src, FileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		if profile != "" {
+			return c, fmt.Errorf("profile %q: no %s in %q", profile, FileName, src)
+		}
+		return c, nil
+	}
+	if err != nil {
+		return c, fmt.Errorf("read %s: %w", FileName, err)
+	}
+
+	var f File
+	if err := yaml.UnmarshalStrict(b, &f); err != nil {
+		return c, fmt.Errorf("parse %s: %w", FileName, err)
+	}
+
+	c.Merge(f.Settings)
+
+	if profile != "" {
+		p, ok := f.Profiles[profile]
+		if !ok {
+			return c, fmt.Errorf("profile %q not found in %s, available: %s", profile, FileName, f.profileNames())
+		}
+		c.Merge(p)
+	}
+
+	// paths in the file are relative to the source dir
+	for _, p := range []*string{&c.Dist, &c.RobotsTxt} {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(src, *p)
+		}
+	}
+	c.Theme = themePath(src, c.Theme)
+
+	return c, nil
+}
+
+// the theme is either a path or the name of a bundled theme. A bare name, such
+// as default, is only taken as path relative to the source dir, if the source
+// dir has a theme of that name
+func themePath(src, theme string) string {
+	if theme == "" || filepath.IsAbs(theme) {
+		return theme
+	}
+	if !strings.ContainsRune(theme, '/') && !strings.ContainsRune(theme, filepath.Separator) {
+		if _, err := os.Stat(filepath.Join(src, theme)); err != nil {
+			return theme
+		}
+	}
+	return filepath.Join(src, theme)
+}
+
+// load the config for the source dir given as first argument, and apply all
+// flags that have been set, either on the command line or via environment, on
+// top of it
+func FromContext(cCtx *cli.Context) (Config, error) {
+	c, err := Load(cCtx.Args().First(), cCtx.String("profile"))
+	if err != nil {
+		return c, err
+	}
+
+	if cCtx.IsSet("dist") {
+		c.Dist = cCtx.String("dist")
+	}
+	if cCtx.IsSet("theme") {
+		c.Theme = cCtx.String("theme")
+	}
+	if cCtx.IsSet("author") {
+		c.Author = cCtx.String("author")
+	}
+	if cCtx.IsSet("context") {
+		c.Context = cCtx.String("context")
+	}
+	if cCtx.IsSet("chroma-style") {
+		c.ChromaStyle = cCtx.String("chroma-style")
+	}
+	if cCtx.IsSet("clean") {
+		c.Clean = cCtx.Bool("clean")
+	}
+	if cCtx.IsSet("jobs") {
+		c.Jobs = cCtx.Int("jobs")
+	}
+	if cCtx.IsSet("port") {
+		c.Port = cCtx.Int("port")
+	}
+	if cCtx.IsSet("drafts") {
+		c.Drafts = cCtx.Bool("drafts")
+	}
+	if cCtx.IsSet("date-fallback") {
+		c.DateFallback = cCtx.StringSlice("date-fallback")
+	}
+	if cCtx.IsSet("acronyms") {
+		c.Acronyms = cCtx.StringSlice("acronyms")
+	}
+	if cCtx.IsSet("frontmatter") {
+		c.FrontMatter = cCtx.Bool("frontmatter")
+	}
+	if cCtx.IsSet("minify-resources") {
+		c.MinifyResources = cCtx.Bool("minify-resources")
+	}
+	if cCtx.IsSet("ignore") {
+		c.Ignore = cCtx.StringSlice("ignore")
+	}
+	if cCtx.IsSet("base-url") {
+		c.BaseURL = cCtx.String("base-url")
+	}
+	if cCtx.IsSet("feed-limit") {
+		c.FeedLimit = cCtx.Int("feed-limit")
+	}
+	if cCtx.IsSet("feed-full-content") {
+		c.FeedFullContent = cCtx.Bool("feed-full-content")
+	}
+	if cCtx.IsSet("section-feeds") {
+		c.SectionFeeds = cCtx.Bool("section-feeds")
+	}
+	if cCtx.IsSet("robots-txt") {
+		c.RobotsTxt = cCtx.String("robots-txt")
+	}
+
+	return c, c.Validate()
+}
+
+// report settings that are invalid, regardless of where they have been set
+func (c Config) Validate() error {
+	if c.BaseURL != "" {
+		u, err := url.Parse(c.BaseURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("base-url: %q must be an absolute url, i.e. https://example.com", c.BaseURL)
+		}
+	}
+	if c.FeedLimit < 0 {
+		return fmt.Errorf("feed-limit: must not be negative")
+	}
+	for _, s := range c.DateFallback {
+		if _, err := engine.ParseDateSource(s); err != nil {
+			return fmt.Errorf("date-fallback: %w", err)
+		}
+	}
+	return nil
+}
+
+// override the settings of c with all settings that are set in o
+func (c *Config) Merge(o Settings) {
+	if o.Dist != nil {
+		c.Dist = *o.Dist
+	}
+	if o.Theme != nil {
+		c.Theme = *o.Theme
+	}
+	if o.Author != nil {
+		c.Author = *o.Author
+	}
+	if o.Context != nil {
+		c.Context = *o.Context
+	}
+	if o.ChromaStyle != nil {
+		c.ChromaStyle = *o.ChromaStyle
+	}
+	if o.Clean != nil {
+		c.Clean = *o.Clean
+	}
+	if o.Jobs != nil {
+		c.Jobs = *o.Jobs
+	}
+	if o.Port != nil {
+		c.Port = *o.Port
+	}
+	if o.Drafts != nil {
+		c.Drafts = *o.Drafts
+	}
+	if o.DateFallback != nil {
+		c.DateFallback = o.DateFallback
+	}
+	if o.Acronyms != nil {
+		c.Acronyms = o.Acronyms
+	}
+	if o.FrontMatter != nil {
+		c.FrontMatter = *o.FrontMatter
+	}
+	if o.MinifyResources != nil {
+		c.MinifyResources = *o.MinifyResources
+	}
+	if o.Ignore != nil {
+		c.Ignore = o.Ignore
+	}
+	if o.BaseURL != nil {
+		c.BaseURL = *o.BaseURL
+	}
+	if o.FeedLimit != nil {
+		c.FeedLimit = *o.FeedLimit
+	}
+	if o.FeedFullContent != nil {
+		c.FeedFullContent = *o.FeedFullContent
+	}
+	if o.SectionFeeds != nil {
+		c.SectionFeeds = *o.SectionFeeds
+	}
+	if o.RobotsTxt != nil {
+		c.RobotsTxt = *o.RobotsTxt
+	}
+}
+
+// the engine options corresponding to the config
+func (c Config) Options() []engine.Option {
+	dateFallback := make([]engine.DateSource, len(c.DateFallback))
+	for i, s := range c.DateFallback {
+		dateFallback[i] = engine.DateSource(s)
+	}
+	return []engine.Option{
+		engine.WithSource(c.Source),
+		engine.WithDist(c.Dist),
+		engine.WithTheme(c.Theme),
+		engine.WithAuthor(c.Author),
+		engine.WithContextPath(c.Context),
+		engine.WithChromaStyle(c.ChromaStyle),
+		engine.WithClean(c.Clean),
+		engine.WithJobs(c.Jobs),
+		engine.WithDateFallback(dateFallback...),
+		engine.WithAcronyms(c.Acronyms...),
+		engine.WithFrontMatter(c.FrontMatter),
+		engine.WithMinifyResources(c.MinifyResources),
+		engine.WithIgnore(c.Ignore...),
+		engine.WithDrafts(c.Drafts),
+		engine.WithBaseURL(c.BaseURL),
+		engine.WithFeeds(engine.FeedOptions{
+			Limit:       c.FeedLimit,
+			FullContent: c.FeedFullContent,
+			Sections:    c.SectionFeeds,
+		}),
+		engine.WithRobots(c.RobotsTxt),
+	}
+}
+
+func (f File) profileNames() string {
+	names := make([]string, 0, len(f.Profiles))
+	for name := range f.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
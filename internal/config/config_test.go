@@ -0,0 +1,91 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bluebrown/doktri/internal/theme"
+)
+
+func TestLoadProfileOverridesZeroValues(t *testing.T) {
+	src := t.TempDir()
+	file := `
+clean: true
+frontmatter: true
+jobs: 4
+acronyms: [API]
+profiles:
+  prod:
+    clean: false
+    frontmatter: false
+    jobs: 0
+    acronyms: []
+`
+	if err := os.WriteFile(filepath.Join(src, FileName), []byte(file), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := Load(src, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Clean || !c.FrontMatter || c.Jobs != 4 || len(c.Acronyms) != 1 {
+		t.Errorf("top level settings not applied: %+v", c)
+	}
+
+	c, err = Load(src, "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Clean || c.FrontMatter || c.Jobs != 0 || len(c.Acronyms) != 0 {
+		t.Errorf("profile did not override the top level settings: %+v", c)
+	}
+	if c.Port != Defaults().Port {
+		t.Errorf("port = %d, want the default %d", c.Port, Defaults().Port)
+	}
+}
+
+func TestLoadTheme(t *testing.T) {
+	src := t.TempDir()
+	if err := os.Mkdir(filepath.Join(src, "mytheme"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		theme string
+		want  string
+	}{
+		{"default", "default"},
+		{"mytheme", filepath.Join(src, "mytheme")},
+		{"themes/blog", filepath.Join(src, "themes/blog")},
+		{"theme.zip", "theme.zip"},
+		{"/opt/themes/blog", "/opt/themes/blog"},
+	}
+
+	for _, tt := range tests {
+		file := "theme: " + tt.theme + "\n"
+		if err := os.WriteFile(filepath.Join(src, FileName), []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+		c, err := Load(src, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Theme != tt.want {
+			t.Errorf("theme %q: got %q, want %q", tt.theme, c.Theme, tt.want)
+		}
+	}
+
+	// the bundled theme can be opened, regardless of the source dir
+	if err := os.WriteFile(filepath.Join(src, FileName), []byte("theme: default\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Load(src, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := theme.Open(c.Theme); err != nil {
+		t.Errorf("open bundled theme: %v", err)
+	}
+}
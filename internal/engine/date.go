@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// a source to take the date of an undated post from
+type DateSource string
+
+const (
+	// the date key of the front matter
+	DateSourceFrontMatter DateSource = "frontmatter"
+	// the time of the last git commit that touched the file
+	DateSourceGit DateSource = "git"
+	// the modification time of the file
+	DateSourceModTime DateSource = "mtime"
+)
+
+// parse the date source, i.e. from the config
+func ParseDateSource(s string) (DateSource, error) {
+	switch ds := DateSource(s); ds {
+	case DateSourceFrontMatter, DateSourceGit, DateSourceModTime:
+		return ds, nil
+	}
+	return "", fmt.Errorf("unknown date source %q, must be one of %s, %s, %s",
+		s, DateSourceFrontMatter, DateSourceGit, DateSourceModTime)
+}
+
+// matches the yyyy-mm-dd- prefix of dated file names
+var datePrefix = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}-`)
+
+// the layouts accepted for dates in the front matter
+var dateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// parse the date of the file name prefix. The result is false, if the name has
+// no valid date prefix
+func parseDatePrefix(name string) (time.Time, bool) {
+	if !datePrefix.MatchString(name) {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("2006-01-02", name[:10])
+	return t, err == nil
+}
+
+// get the date of an undated leaf, or the index of a dir, from the first source
+// of the date fallback, that has one
+func (n *TreeNode) fallbackDate() time.Time {
+	for _, src := range n.Site.DateFallback {
+		var (
+			t  time.Time
+			ok bool
+		)
+		switch src {
+		case DateSourceFrontMatter:
+			t, ok = n.frontMatterDate()
+		case DateSourceGit:
+			t, ok = n.gitDate()
+		case DateSourceModTime:
+			t, ok = n.modTime()
+		}
+		if ok {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (n *TreeNode) frontMatterDate() (time.Time, bool) {
+	fm := n.front()
+	return fm.Date, !fm.Date.IsZero()
+}
+
+// the commit time of the last commit touching the file. If git is not
+// available, or the file is not tracked, the result is false
+func (n *TreeNode) gitDate() (time.Time, bool) {
+	if n.Site.docs == "" {
+		return time.Time{}, false
+	}
+	cmd := exec.Command("git", "log", "-1", "--format=%cI", "--", filepath.FromSlash(n.contentPath()))
+	cmd.Dir = n.Site.docs
+	out, err := cmd.Output()
+	if err != nil {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(string(out)))
+	return t, err == nil
+}
+
+func (n *TreeNode) modTime() (time.Time, bool) {
+	info, err := fs.Stat(n.fs, n.contentPath())
+	if err != nil {
+		return time.Time{}, false
+	}
+	return info.ModTime(), true
+}
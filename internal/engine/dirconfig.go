@@ -0,0 +1,114 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+
+	"sigs.k8s.io/yaml"
+)
+
+// the name of the optional config file in a docs dir
+const DirConfigName = "_dir.yaml"
+
+// the content of a _dir.yaml. It configures the dir node of the dir it is
+// placed in. Title, description and naming apply to that dir only, all other
+// settings cascade down to the descendants of the dir, unless they override
+// them
+type DirConfig struct {
+	// the title of the dir, instead of the one derived from its name
+	Title string `json:"title,omitempty"`
+	// a description of the dir
+	Description string `json:"description,omitempty"`
+	// the author of the posts in the dir, instead of the site author
+	Author string `json:"author,omitempty"`
+	// the layout to render the dir page with. It is relative to the layouts
+	// dir of the theme and without the .html extension, i.e. blog/list
+	Layout string `json:"layout,omitempty"`
+	// how the names of the entries of the dir are interpreted
+	Naming NamingScheme `json:"naming,omitempty"`
+	// how the entries of the dir are ordered
+	Sort SortConfig `json:"sort,omitempty"`
+	// arbitrary parameters to use in the templates. Descendants inherit the
+	// parameters of their ancestors, key by key
+	Params map[string]any `json:"params,omitempty"`
+	// keep the dir and everything below out of the sitemap and search
+	// engines
+	NoIndex bool `json:"noindex,omitempty"`
+}
+
+// the order of the entries of a dir
+type SortConfig struct {
+	// the key to sort by
+	By SortKey `json:"by,omitempty"`
+	// the direction to sort in. The default depends on the key
+	Direction SortDirection `json:"direction,omitempty"`
+}
+
+// apply the own config of a dir on top of the cascading settings of its
+// parent. Title, description and naming are not inherited
+func (c DirConfig) cascade(own DirConfig) DirConfig {
+	out := DirConfig{
+		Title:       own.Title,
+		Description: own.Description,
+		Naming:      own.Naming,
+		Author:      c.Author,
+		Layout:      c.Layout,
+		Sort:        c.Sort,
+		NoIndex:     c.NoIndex || own.NoIndex,
+		Params:      make(map[string]any, len(c.Params)+len(own.Params)),
+	}
+	if own.Author != "" {
+		out.Author = own.Author
+	}
+	if own.Layout != "" {
+		out.Layout = own.Layout
+	}
+	if own.Sort.By != "" {
+		out.Sort.By = own.Sort.By
+	}
+	if own.Sort.Direction != "" {
+		out.Sort.Direction = own.Sort.Direction
+	}
+	for k, v := range c.Params {
+		out.Params[k] = v
+	}
+	for k, v := range own.Params {
+		out.Params[k] = v
+	}
+	return out
+}
+
+// read the _dir.yaml of the given dir. If there is none, the zero config is
+// returned
+func readDirConfig(fsys fs.FS, dir string) (DirConfig, error) {
+	var c DirConfig
+	p := path.Join(dir, DirConfigName)
+	b, err := fs.ReadFile(fsys, p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	if err := yaml.UnmarshalStrict(b, &c); err != nil {
+		return c, fmt.Errorf("parse %s: %w", p, err)
+	}
+	if c.Naming != "" {
+		if c.Naming, err = ParseNamingScheme(string(c.Naming)); err != nil {
+			return c, fmt.Errorf("%s: %w", p, err)
+		}
+	}
+	if c.Sort.By != "" {
+		if c.Sort.By, err = ParseSortKey(string(c.Sort.By)); err != nil {
+			return c, fmt.Errorf("%s: %w", p, err)
+		}
+	}
+	if c.Sort.Direction != "" {
+		if c.Sort.Direction, err = ParseSortDirection(string(c.Sort.Direction)); err != nil {
+			return c, fmt.Errorf("%s: %w", p, err)
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,195 @@
+package engine
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"go.abhg.dev/goldmark/frontmatter"
+	"go.abhg.dev/goldmark/toc"
+)
+
+// a parsed markdown document. The source is parsed once, when the document is
+// created, and everything derived from it is computed on first use and then
+// kept, so that the template funcs and node methods can share it
+type document struct {
+	md     goldmark.Markdown
+	source []byte
+	root   ast.Node
+	front  *frontmatter.Data
+
+	htmlOnce sync.Once
+	html     string
+	htmlErr  error
+
+	tocOnce sync.Once
+	toc     string
+	tocErr  error
+
+	excerptOnce sync.Once
+	excerpt     string
+	excerptErr  error
+
+	headingOnce sync.Once
+	heading     string
+}
+
+func parseDocument(md goldmark.Markdown, source []byte) *document {
+	ctx := parser.NewContext()
+	root := md.Parser().Parse(text.NewReader(source), parser.WithContext(ctx))
+	return &document{md: md, source: source, root: root, front: frontmatter.Get(ctx)}
+}
+
+// the document rendered to html
+func (d *document) HTML() (string, error) {
+	d.htmlOnce.Do(func() {
+		var buf bytes.Buffer
+		d.htmlErr = d.md.Renderer().Render(&buf, d.source, d.root)
+		d.html = buf.String()
+	})
+	return d.html, d.htmlErr
+}
+
+// the table of contents as html ul element, without the top level heading
+func (d *document) TOC() (string, error) {
+	d.tocOnce.Do(func() {
+		d.toc, d.tocErr = d.renderTOC()
+	})
+	return d.toc, d.tocErr
+}
+
+func (d *document) renderTOC() (string, error) {
+	tree, err := toc.Inspect(d.root, d.source)
+	if err != nil {
+		return "", err
+	}
+	list := toc.RenderList(tree)
+	if list == nil {
+		return "", nil
+	}
+
+	// the first child is the first list item
+	n := list.FirstChild()
+	if n == nil {
+		return "", nil
+	}
+
+	// the first child of that is the anchor
+	n = n.FirstChild()
+	if n == nil {
+		return "", nil
+	}
+
+	// the sibling of that is the nested ul
+	n = n.NextSibling()
+	if n == nil {
+		return "", nil
+	}
+
+	buf := new(bytes.Buffer)
+	if err := d.md.Renderer().Render(buf, d.source, n); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// the first paragraph, after the title, as html. It is empty, if the document
+// has no paragraph there
+func (d *document) Excerpt() (string, error) {
+	d.excerptOnce.Do(func() {
+		first := d.root.FirstChild()
+		if first == nil || first.NextSibling() == nil {
+			return
+		}
+		buf := new(bytes.Buffer)
+		d.excerptErr = d.md.Renderer().Render(buf, d.source, first.NextSibling())
+		d.excerpt = buf.String()
+	})
+	return d.excerpt, d.excerptErr
+}
+
+// the text of the first level 1 heading. It is empty, if there is none
+func (d *document) Heading() string {
+	d.headingOnce.Do(func() {
+		ast.Walk(d.root, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+			if h, ok := node.(*ast.Heading); ok && entering && h.Level == 1 {
+				d.heading = strings.TrimSpace(plainText(h, d.source))
+				return ast.WalkStop, nil
+			}
+			return ast.WalkContinue, nil
+		})
+	})
+	return d.heading
+}
+
+// the destinations of all links and images, in document order
+func (d *document) Links() []string {
+	var links []string
+	ast.Walk(d.root, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := node.(type) {
+		case *ast.Link:
+			links = append(links, string(n.Destination))
+		case *ast.Image:
+			links = append(links, string(n.Destination))
+		}
+		return ast.WalkContinue, nil
+	})
+	return links
+}
+
+// the metadata of the document, as set by the front matter extension
+func (d *document) Meta() map[string]any {
+	return d.root.OwnerDocument().Meta()
+}
+
+// decode the front matter into dst. The result is false, if the document has
+// no front matter
+func (d *document) DecodeFront(dst any) (bool, error) {
+	if d.front == nil {
+		return false, nil
+	}
+	return true, d.front.Decode(dst)
+}
+
+// the documents parsed during a build, by their source. Documents are looked
+// up by their content rather than their node, so that the template funcs,
+// which only get the content, share them with the nodes. A new cache is used
+// for each build, so that changed sources are parsed again
+type documentCache struct {
+	md   goldmark.Markdown
+	mu   sync.Mutex
+	docs map[string]*documentEntry
+}
+
+// the entry allows to parse a document outside the lock of the cache, while
+// other callers wait for the same document only
+type documentEntry struct {
+	once sync.Once
+	doc  *document
+}
+
+func newDocumentCache(md goldmark.Markdown) *documentCache {
+	return &documentCache{md: md, docs: make(map[string]*documentEntry)}
+}
+
+// get the parsed document for the source, parsing it on first use
+func (c *documentCache) get(source []byte) *document {
+	c.mu.Lock()
+	entry, ok := c.docs[string(source)]
+	if !ok {
+		entry = &documentEntry{}
+		c.docs[string(source)] = entry
+	}
+	c.mu.Unlock()
+	entry.once.Do(func() {
+		entry.doc = parseDocument(c.md, source)
+	})
+	return entry.doc
+}
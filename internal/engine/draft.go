@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"path"
+	"strings"
+)
+
+const (
+	// files and dirs with this prefix are drafts. The prefix is not part of
+	// the name, so that the path stays the same, once the draft is published
+	draftPrefix = "_"
+	// everything in dirs with this name is a draft
+	draftsDir = "drafts"
+)
+
+// the file name of the node, without the draft prefix
+func (n *TreeNode) baseName() string {
+	return strings.TrimPrefix(path.Base(n.SourcePath), draftPrefix)
+}
+
+// return true if the node is a draft or scheduled, meaning it is not part of
+// the site, unless drafts are included, i.e. with serve --drafts. A node is a
+// draft, if its name has the _ prefix, it is in a drafts dir, its front matter
+// has draft: true, or any of its parents is a draft
+func (n *TreeNode) IsDraft() bool {
+	if n.IsRoot {
+		return false
+	}
+	return n.isOwnDraft() || n.IsScheduled() || n.Parent.IsDraft()
+}
+
+// return true if the node is a leaf, whose date is after the time of the build
+func (n *TreeNode) IsScheduled() bool {
+	if !n.IsLeaf || n.Site.buildTime.IsZero() {
+		return false
+	}
+	return n.date().After(n.Site.buildTime)
+}
+
+// report whether the node itself is marked as draft, regardless of its parents
+func (n *TreeNode) isOwnDraft() bool {
+	name := path.Base(n.SourcePath)
+	if strings.HasPrefix(name, draftPrefix) {
+		return true
+	}
+	if !n.IsLeaf && name == draftsDir {
+		return true
+	}
+	return n.front().Draft
+}
+
+// report whether the resource is a draft, that is its name, or the name of a
+// dir between it and its owner, has the _ prefix, or such a dir is named
+// drafts. Resources in draft dirs with nodes go along with the node of their
+// dir
+func (r *Resource) isDraft() bool {
+	parts := strings.Split(r.Name, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, draftPrefix) || (i < len(parts)-1 && part == draftsDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// remove the drafts and scheduled nodes, and the draft resources, from the
+// tree below this node
+func (n *TreeNode) pruneDrafts() {
+	resources := n.resources[:0]
+	for _, r := range n.resources {
+		if !r.isDraft() {
+			resources = append(resources, r)
+		}
+	}
+	n.resources = resources
+
+	children := n.Children[:0]
+	for _, c := range n.Children {
+		if c.isOwnDraft() || c.IsScheduled() {
+			continue
+		}
+		c.pruneDrafts()
+		children = append(children, c)
+	}
+	// clear the tail, so the pruned nodes can be collected
+	for i := len(children); i < len(n.Children); i++ {
+		n.Children[i] = nil
+	}
+	n.Children = children
+}
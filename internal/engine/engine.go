@@ -2,15 +2,20 @@ package engine
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"mime"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
+	"sync"
 	"text/template"
+	"time"
 
 	"github.com/Masterminds/sprig/v3"
 	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
@@ -30,6 +35,15 @@ import (
 	"sigs.k8s.io/yaml"
 
 	"github.com/bluebrown/doktri/internal/fsys"
+	"github.com/bluebrown/doktri/internal/ignore"
+	"github.com/bluebrown/doktri/internal/theme"
+)
+
+const (
+	// the locations of templates and assets, relative to the theme root
+	themeLayoutsDir  = "templates/layouts"
+	themeIncludesDir = "templates/includes"
+	themeAssetsDir   = "assets"
 )
 
 type Engine struct {
@@ -37,15 +51,24 @@ type Engine struct {
 	docs        string
 	dist        string
 	theme       string
+	themeFS     fs.FS
+	loadedTheme fs.FS
 	chromaStyle string
+	clean       bool
+	jobs        int
+	minifyRes   bool
+	ignore      []string
+	feeds       FeedOptions
+	robotsPath  string
 	markdown    goldmark.Markdown
 	minifier    *minify.M
 	meta        map[string]any
+	site        *Site
 }
 
 func New(options ...Option) Engine {
 	// apply the options
-	opts := Options{}
+	opts := Options{site: NewSite()}
 	for _, o := range options {
 		o(&opts)
 	}
@@ -58,8 +81,13 @@ func New(options ...Option) Engine {
 		opts.dist = filepath.Join(opts.source, "dist")
 	}
 
-	if opts.theme == "" {
+	// use the theme of the project, and fall back to the bundled theme, if
+	// the project has none
+	if opts.theme == "" && opts.themeFS == nil {
 		opts.theme = filepath.Join(opts.source, ".theme")
+		if ok, _ := fsys.PathExists(opts.theme); !ok {
+			opts.theme = theme.DefaultName
+		}
 	}
 
 	if opts.chromaStyle == "" {
@@ -90,6 +118,10 @@ func New(options ...Option) Engine {
 		),
 	)
 
+	// let the nodes inspect their sources
+	opts.site.markdown = md
+	opts.site.docs = filepath.Join(opts.source, "docs")
+
 	m := minify.New()
 	m.AddFunc("text/html", minihtml.Minify)
 	m.AddFunc("text/css", css.Minify)
@@ -100,10 +132,18 @@ func New(options ...Option) Engine {
 		docs:        filepath.Join(opts.source, "docs"),
 		dist:        opts.dist,
 		theme:       opts.theme,
+		themeFS:     opts.themeFS,
 		chromaStyle: opts.chromaStyle,
+		clean:       opts.clean,
+		jobs:        opts.jobs,
+		minifyRes:   opts.minifyRes,
+		ignore:      opts.ignore,
+		feeds:       opts.feeds,
+		robotsPath:  opts.robots,
 		markdown:    md,
 		minifier:    m,
 		meta:        make(map[string]any),
+		site:        opts.site,
 	}
 }
 
@@ -113,38 +153,32 @@ func (e Engine) FuncMap() template.FuncMap {
 }
 
 func (e Engine) MakeLayout(name string) (*template.Template, error) {
-	var err error
+	themeFS, err := e.ThemeFS()
+	if err != nil {
+		return nil, err
+	}
 
 	tpl := template.New("base.html")
 	tpl.Funcs(textfunc.MapClosure(sprig.TxtFuncMap(), tpl)).Funcs(e.FuncMap())
 
-	tpl, err = tpl.ParseFiles(
-		filepath.Join(e.ThemeTemplatesDir(), "layouts", "base.html"),
-		filepath.Join(e.ThemeTemplatesDir(), "layouts", name+".html"),
+	tpl, err = tpl.ParseFS(themeFS,
+		path.Join(themeLayoutsDir, "base.html"),
+		path.Join(themeLayoutsDir, name+".html"),
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	inc := filepath.Join(e.ThemeTemplatesDir(), "includes")
-
 	// skip includes if there are none
-	exists, err := fsys.PathExists(inc)
-	if err != nil {
-		return nil, fmt.Errorf("check includes exist: %w", err)
-	}
-	if !exists {
-		return tpl, nil
-	}
-	empty, err := fsys.IsEmptyDir(inc)
+	includes, err := fs.Glob(themeFS, path.Join(themeIncludesDir, "*"))
 	if err != nil {
-		return nil, fmt.Errorf("check includes empty: %w", err)
+		return nil, fmt.Errorf("glob includes: %w", err)
 	}
-	if empty {
+	if len(includes) == 0 {
 		return tpl, nil
 	}
 
-	return tpl.ParseGlob(filepath.Join(inc, "*"))
+	return tpl.ParseFS(themeFS, includes...)
 }
 
 func (e Engine) SourceDir() string {
@@ -155,16 +189,54 @@ func (e Engine) DocsDir() string {
 	return e.docs
 }
 
-func (e Engine) ThemeTemplatesDir() string {
-	return filepath.Join(e.theme, "templates")
+// the theme as file system. The theme is stacked on top of the themes it
+// extends, and the templates dir of the project is stacked on top of all of
+// them, so that project templates override theme templates of the same name.
+// Themes are loaded once per build, outside of a build they are loaded on
+// each call, so that changes to them are picked up
+func (e Engine) ThemeFS() (fs.FS, error) {
+	if e.loadedTheme != nil {
+		return e.loadedTheme, nil
+	}
+	fsys, _, err := e.loadTheme()
+	return fsys, err
 }
 
-func (e Engine) DistDir() string {
-	return e.dist
+// the local dirs of the theme and the themes it extends. Archives and bundled
+// themes have no dir
+func (e Engine) ThemeDirs() []string {
+	_, dirs, err := e.loadTheme()
+	if err != nil {
+		return nil
+	}
+	return dirs
+}
+
+func (e Engine) loadTheme() (fs.FS, []string, error) {
+	var (
+		base fs.FS
+		dirs []string
+		err  error
+	)
+	if e.themeFS != nil {
+		base, dirs, err = theme.Extend(e.themeFS, "")
+	} else {
+		base, dirs, err = theme.Load(e.theme)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	project := theme.Subtree(os.DirFS(e.src), filepath.Base(e.TemplatesDir()))
+	return theme.Layers(project, base), dirs, nil
 }
 
-func (e Engine) ThemeAssetsDir() string {
-	return filepath.Join(e.theme, "assets")
+// the dir of the project templates, that override the theme templates
+func (e Engine) TemplatesDir() string {
+	return filepath.Join(e.src, "templates")
+}
+
+func (e Engine) DistDir() string {
+	return e.dist
 }
 
 func (e Engine) ExtraAssetsDir() string {
@@ -175,23 +247,93 @@ func (e Engine) MetaPath() string {
 	return filepath.Join(e.src, "meta.yaml")
 }
 
+// the path of the .doktriignore of the source dir
+func (e Engine) IgnorePath() string {
+	return filepath.Join(e.src, ignore.FileName)
+}
+
+// load the ignore rules of the source dir. Paths are matched relative to the
+// source dir, i.e. docs/notes.md
+func (e Engine) Ignore() (*ignore.Matcher, error) {
+	return ignore.Load(e.src, e.ignore...)
+}
+
 func (e Engine) Meta() map[string]any {
 	return e.meta
 }
 
+func (e Engine) Site() *Site {
+	return e.site
+}
+
+// build the site into a staging dir next to dist, and swap it with dist once
+// the build succeeded. A failed build leaves the last good site untouched. On
+// linux the swap is atomic, so dist always contains a complete site. Elsewhere
+// dist is missing for the moment between moving the old one aside and moving
+// the new one in
 func (e Engine) Run() error {
-	var err error
-	// reset the dist dir
-	if err := os.RemoveAll(e.DistDir()); err != nil {
-		return fmt.Errorf("clean dist: %w", err)
+	dist := filepath.Clean(e.DistDir())
+	if err := os.MkdirAll(filepath.Dir(dist), 0755); err != nil {
+		return fmt.Errorf("create dist parent: %w", err)
+	}
+
+	stage, err := os.MkdirTemp(filepath.Dir(dist), "."+filepath.Base(dist)+"-*")
+	if err != nil {
+		return fmt.Errorf("create staging dir: %w", err)
+	}
+	// once the staging dir has been swapped in, this is a no-op
+	defer os.RemoveAll(stage)
+
+	if err := os.Chmod(stage, 0755); err != nil {
+		return fmt.Errorf("chmod staging dir: %w", err)
+	}
+
+	// a clean build starts with an empty staging dir, otherwise the build is
+	// incremental. The staging dir is seeded with the outputs the previous
+	// manifest knows about, and only outputs whose inputs changed since the
+	// last build are written. Anything else in dist is not carried over, so
+	// without a usable manifest, the build starts from scratch
+	prev := NewManifest()
+	if !e.clean {
+		prev, err = ReadManifest(dist)
+		if err != nil {
+			return fmt.Errorf("read manifest: %w", err)
+		}
+		outputs := make([]string, 0, len(prev.Outputs))
+		for out := range prev.Outputs {
+			outputs = append(outputs, out)
+		}
+		if err := fsys.LinkFiles(dist, stage, outputs); err != nil {
+			return fmt.Errorf("seed staging dir: %w", err)
+		}
+	}
+
+	// load the theme once for the whole build
+	e.loadedTheme, err = e.ThemeFS()
+	if err != nil {
+		return fmt.Errorf("open theme: %w", err)
+	}
+
+	if err := e.build(stage, prev); err != nil {
+		return err
 	}
 
-	if err := os.MkdirAll(e.DistDir(), 0755); err != nil {
-		return fmt.Errorf("create dist: %w", err)
+	if err := fsys.ReplaceDir(stage, dist); err != nil {
+		return fmt.Errorf("swap dist: %w", err)
 	}
 
+	return nil
+}
+
+// build the site into the out dir. Outputs that are fresh according to the
+// previous manifest are expected to be present in out already
+func (e Engine) build(out string, prev *Manifest) error {
+	var err error
+
+	next := NewManifest()
+
 	// initialize the walker
-	walker := TreeWalker{mini: e.minifier}
+	walker := TreeWalker{mini: e.minifier, prev: prev, next: next, jobs: e.jobs}
 
 	// read the meta file
 	exists, err := fsys.PathExists(e.MetaPath())
@@ -208,55 +350,138 @@ func (e Engine) Run() error {
 		}
 	}
 
-	walker.dirTpl, err = e.MakeLayout("dir")
-	if err != nil {
-		return fmt.Errorf("read dir tpl: %w", err)
-	}
+	walker.layouts = newLayouts(e.loadedTheme, e.MakeLayout)
 
-	walker.fileTpl, err = e.MakeLayout("file")
+	// posts dated after the start of the build are scheduled
+	e.site.buildTime = time.Now()
+
+	// parse each document once per build, and share it between the nodes
+	// and the template funcs
+	e.site.documents = newDocumentCache(e.markdown)
+	defer func() { e.site.documents = nil }()
+
+	// ignored paths are hidden from the tree and the asset copy
+	ignored, err := e.Ignore()
 	if err != nil {
-		return fmt.Errorf("read file tpl: %w", err)
+		return fmt.Errorf("load %s: %w", ignore.FileName, err)
 	}
 
-	walker.srcFS = os.DirFS(e.DocsDir())
-	walker.distPath = e.DistDir()
+	walker.srcFS = ignore.FS(os.DirFS(e.DocsDir()), ignored, "docs")
+	walker.distPath = out
 
 	// build a new tree from the src FS
-	treeRoot, err := buildTree(walker.srcFS)
+	treeRoot, err := buildTree(walker.srcFS, e.site)
 	if err != nil {
 		return fmt.Errorf("walk: %w", err)
 	}
 
-	// sort the tree by date and then walk it
-	err = walker.RenderWalk(treeRoot.SortDate(SortDirectionDescending))
+	// drafts and scheduled posts are only part of the site on demand
+	if !e.site.Drafts {
+		treeRoot.pruneDrafts()
+	}
+
+	// pages must not overwrite each other
+	if err := checkPaths(treeRoot); err != nil {
+		return err
+	}
+
+	// posts get the resources they link to
+	if err := attachResources(treeRoot); err != nil {
+		return err
+	}
+
+	// sort the tree by the naming scheme of each dir
+	treeRoot.SortDefault()
+
+	inputs, err := e.inputsFingerprint()
+	if err != nil {
+		return fmt.Errorf("fingerprint inputs: %w", err)
+	}
+	walker.fingerprints, err = fingerprintTree(treeRoot, inputs)
 	if err != nil {
+		return fmt.Errorf("fingerprint: %w", err)
+	}
+
+	// walk the tree to render the pages
+	if err := walker.RenderWalk(treeRoot); err != nil {
 		return fmt.Errorf("walk: %w", err)
 	}
+	fmt.Printf("\nrendered %d pages, %d unchanged\n", walker.rendered, walker.skipped)
 
-	// dist assets is the location in dist to put additional assets
-	distAssets := filepath.Join(e.DistDir(), filepath.Base(e.ThemeAssetsDir()))
+	themeAssets, err := fs.Sub(e.loadedTheme, themeAssetsDir)
+	if err != nil {
+		return fmt.Errorf("theme assets: %w", err)
+	}
 
 	// copy first the theme assets and then the extra assets
-	for _, p := range []string{e.ThemeAssetsDir(), e.ExtraAssetsDir()} {
-		// copy assets to dist assets if any
-		ok, err := fsys.PathExists(p)
-		if err != nil {
-			return fmt.Errorf("check assets: %w", err)
-		}
-		if ok {
-			if err := e.copyAssets(p, distAssets); err != nil {
-				return fmt.Errorf("copy assets: %w", err)
-			}
+	extraAssets := ignore.FS(os.DirFS(e.ExtraAssetsDir()), ignored, "assets")
+	if err := e.copyAssets(out, prev, next, themeAssets, extraAssets); err != nil {
+		return fmt.Errorf("copy assets: %w", err)
+	}
+
+	// copy the resources of the docs dir next to their pages
+	if err := e.copyResources(out, prev, next, treeRoot); err != nil {
+		return fmt.Errorf("copy resources: %w", err)
+	}
+
+	if err := e.writeStyles(out, prev, next); err != nil {
+		return err
+	}
+
+	if err := e.writeFeeds(out, prev, next, treeRoot); err != nil {
+		return fmt.Errorf("write feeds: %w", err)
+	}
+
+	if err := e.writeSitemap(out, prev, next, treeRoot); err != nil {
+		return fmt.Errorf("write sitemap: %w", err)
+	}
+
+	// remove everything the previous build produced, that this one did not
+	if _, err := prev.Prune(out, next); err != nil {
+		return fmt.Errorf("prune dist: %w", err)
+	}
+
+	if err := next.Write(out); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+
+	return nil
+}
+
+// fingerprint the inputs that are shared by all pages. These are the theme
+// templates, the meta.yaml and the settings affecting the generated html
+func (e Engine) inputsFingerprint() (string, error) {
+	f := newFingerprint().AddString(e.site.ContextPath, e.site.BaseURL, e.site.Author, e.chromaStyle, fmt.Sprint(e.site.FrontMatter), fmt.Sprint(e.site.Drafts))
+	if err := f.AddFile(e.MetaPath()); err != nil {
+		return "", err
+	}
+	err := fs.WalkDir(e.loadedTheme, "templates", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
 		}
+		return f.AddFS(e.loadedTheme, path)
+	})
+	if err != nil {
+		return "", err
+	}
+	return f.String(), nil
+}
+
+// generate the chroma.css, if the style has changed since the last build
+func (e Engine) writeStyles(dist string, prev, next *Manifest) error {
+	out := "assets/chroma.css"
+	fp := newFingerprint().AddString(out, e.chromaStyle).String()
+	next.Outputs[out] = fp
+	if prev.Fresh(dist, out, fp) {
+		return nil
 	}
 
-	// ensure dist assets dir, in case it has not been created by
-	// the themes or extra asset copy
-	if err := os.MkdirAll(distAssets, 0755); err != nil {
+	p := filepath.Join(dist, filepath.FromSlash(out))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
 		return fmt.Errorf("create assets dir: %w", err)
 	}
 
-	f, err := os.Create(filepath.Join(e.DistDir(), "assets", "chroma.css"))
+	f, err := fsys.CreateFresh(p)
 	if err != nil {
 		return fmt.Errorf("create chroma.css: %w", err)
 	}
@@ -282,47 +507,120 @@ func (e Engine) Run() error {
 	}
 
 	return nil
-
 }
 
 type TreeWalker struct {
-	distPath string
-	srcFS    fs.FS
-	dirTpl   *template.Template
-	fileTpl  *template.Template
-	mini     *minify.M
+	distPath     string
+	srcFS        fs.FS
+	layouts      *layouts
+	mini         *minify.M
+	prev         *Manifest
+	next         *Manifest
+	fingerprints map[*TreeNode]string
+	jobs         int
+	rendered     int
+	skipped      int
+}
+
+// a page that needs to be rendered
+type renderJob struct {
+	tpl  *template.Template
+	node *TreeNode
+	path string
+}
+
+// walk the tree depth first and render every node that changed since the last
+// build. The walk itself is sequential, so the table output is deterministic,
+// while the pages are rendered and minified concurrently by a pool of workers
+func (tw *TreeWalker) RenderWalk(node *TreeNode) error {
+	jobs := make([]renderJob, 0)
+	if err := tw.plan(node, &jobs); err != nil {
+		return err
+	}
+
+	workers := tw.jobs
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+
+	errs := make([]error, len(jobs))
+	queue := make(chan int)
+	wg := sync.WaitGroup{}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				if err := tw.render(jobs[i].tpl, jobs[i].node, jobs[i].path); err != nil {
+					errs[i] = newRenderError(jobs[i].node, err)
+				}
+			}
+		}()
+	}
+
+	for i := range jobs {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+
+	// report the first error in walk order
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-func (tw TreeWalker) RenderWalk(node *TreeNode) error {
+// record the output of the node in the manifest and add a render job, if the
+// node needs to be rendered. Then repeat for the children recursively
+func (tw *TreeWalker) plan(node *TreeNode, jobs *[]renderJob) error {
+	// every node is rendered as index.html in the dir of its web path,
+	// without the context path, which only applies to links
+	out := node.slug() + "index.html"
+	p := filepath.Join(tw.distPath, filepath.FromSlash(out))
+
+	fp := tw.fingerprints[node]
+	tw.next.Outputs[out] = fp
+
 	if node.IsRoot {
 		fmt.Printf("\n%-25s   %s\n", "PARENT", "PAGE")
 		fmt.Println("────────────────────────────────────────────────────────────────────────────────")
-	} else {
-		fmt.Printf("%-25s < %s\n", node.Parent.Path(), node.Name())
 	}
 
-	// render template
-	var (
-		t *template.Template
-		p string
-	)
-
-	// NOTE: do not use node.Path(), to get the path since we should use the os
-	// specific path separator and node.Path() returns forward slashes as its
-	// meant to be used as web link
-	if node.IsLeaf {
-		t = tw.fileTpl
-		p = filepath.Join(tw.distPath, filepath.Dir(node.SourcePath), NormalizeMdName(filepath.Base(node.SourcePath)), "index.html")
+	// skip the node, if nothing it depends on has changed
+	if tw.prev.Fresh(tw.distPath, out, fp) {
+		tw.skipped++
 	} else {
-		t = tw.dirTpl
-		p = filepath.Join(tw.distPath, node.SourcePath, "index.html")
+		if !node.IsRoot {
+			fmt.Printf("%-25s < %s\n", node.Parent.Path(), node.Name())
+		}
+		t, err := tw.layouts.Get(node)
+		if err != nil {
+			return fmt.Errorf("read layout %s: %w", tw.layouts.Name(node), err)
+		}
+		*jobs = append(*jobs, renderJob{tpl: t, node: node, path: p})
+		tw.rendered++
+	}
+
+	for _, c := range node.Children {
+		if err := tw.plan(c, jobs); err != nil {
+			return err
+		}
 	}
 
+	return nil
+}
+
+func (tw *TreeWalker) render(t *template.Template, node *TreeNode, p string) error {
 	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
 		return fmt.Errorf("create dist dir: %w", err)
 	}
 
-	f, err := os.Create(p)
+	f, err := fsys.CreateFresh(p)
 	if err != nil {
 		return fmt.Errorf("create distr file: %w", err)
 	}
@@ -341,62 +639,131 @@ func (tw TreeWalker) RenderWalk(node *TreeNode) error {
 		return fmt.Errorf("minify html: %w", err)
 	}
 
-	// don't use defer for the file.Close, otherwise we have a lot of open file
-	// descriptors until the whole tree is handled this is because the function
-	// doesn't return until the children are handled.
 	if err := f.Close(); err != nil {
 		return fmt.Errorf("close file: %w", err)
 	}
 
-	// repeat for children recursively
-	for _, c := range node.Children {
-		if err := tw.RenderWalk(c); err != nil {
-			return err
-		}
-	}
 	return nil
 }
 
-func (e Engine) copyAssets(root, target string) error {
-	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return fmt.Errorf("assets walk: %w", err)
+// copy the assets of the given roots to the assets dir in dist. The roots are
+// copied in order, so files of later roots overwrite files of earlier roots.
+// Roots that don't exist are skipped. Assets that did not change since the
+// last build are skipped as well
+func (e Engine) copyAssets(dist string, prev, next *Manifest, roots ...fs.FS) error {
+	type source struct {
+		fsys fs.FS
+		path string
+	}
+
+	// map each output to its source, later roots win
+	sources := make(map[string]source)
+	for _, root := range roots {
+		if _, err := fs.Stat(root, "."); errors.Is(err, fs.ErrNotExist) {
+			continue
 		}
-
-		outPath := filepath.Join(target, strings.TrimPrefix(path, root))
-
-		if d.IsDir() {
-			if err := os.MkdirAll(outPath, 0755); err != nil {
-				return fmt.Errorf("assets copy: create dir: %w", err)
+		err := fs.WalkDir(root, ".", func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return fmt.Errorf("assets walk: %w", err)
+			}
+			if d.IsDir() {
+				return nil
 			}
+			sources[path.Join(themeAssetsDir, p)] = source{root, p}
 			return nil
-		}
-
-		src, err := os.Open(path)
+		})
 		if err != nil {
-			return fmt.Errorf("assets copy: open src: %w", err)
+			return err
 		}
-		defer src.Close()
+	}
 
-		dst, err := os.Create(outPath)
-		if err != nil {
-			return fmt.Errorf("assets copy: open dst: %w", err)
+	// ensure dist assets dir, in case there are no assets to copy
+	if err := os.MkdirAll(filepath.Join(dist, themeAssetsDir), 0755); err != nil {
+		return fmt.Errorf("create assets dir: %w", err)
+	}
+
+	for out, src := range sources {
+		f := newFingerprint().AddString(out)
+		if err := f.AddFS(src.fsys, src.path); err != nil {
+			return fmt.Errorf("assets fingerprint: %w", err)
+		}
+		fp := f.String()
+		next.Outputs[out] = fp
+		if prev.Fresh(dist, out, fp) {
+			continue
+		}
+		if err := e.copyAsset(src.fsys, src.path, filepath.Join(dist, filepath.FromSlash(out)), true); err != nil {
+			return err
 		}
+	}
 
-		defer dst.Close()
+	return nil
+}
 
-		if _, params, m := e.minifier.Match(mime.TypeByExtension(filepath.Ext(path))); m != nil {
-			if err := m.Minify(e.minifier, dst, src, params); err != nil {
-				return fmt.Errorf("assets minify: %s -> %s: %w", path, outPath, err)
+// copy the resources of each node in the tree, unless they are fresh. An error
+// is returned, if a resource would overwrite a page or an asset
+func (e Engine) copyResources(dist string, prev, next *Manifest, root *TreeNode) error {
+	var visit func(n *TreeNode) error
+	visit = func(n *TreeNode) error {
+		for _, r := range n.resources {
+			out := r.outPath()
+			if _, ok := next.Outputs[out]; ok {
+				return fmt.Errorf("%s: conflicts with the output %s", r.SourcePath, out)
+			}
+			f := newFingerprint().AddString(out, fmt.Sprint(e.minifyRes))
+			if err := f.AddFS(n.fs, r.SourcePath); err != nil {
+				return fmt.Errorf("fingerprint %s: %w", r.SourcePath, err)
 			}
-		} else {
-			if _, err := io.Copy(dst, src); err != nil {
-				return fmt.Errorf("assets copy: %s -> %s: %w", path, outPath, err)
+			fp := f.String()
+			next.Outputs[out] = fp
+			if prev.Fresh(dist, out, fp) {
+				continue
+			}
+			if err := e.copyAsset(n.fs, r.SourcePath, filepath.Join(dist, filepath.FromSlash(out)), e.minifyRes); err != nil {
+				return err
+			}
+		}
+		for _, c := range n.Children {
+			if err := visit(c); err != nil {
+				return err
 			}
 		}
-
 		return nil
-	})
+	}
+	return visit(root)
+}
+
+// copy the file to the out path. If minify is true, and the minifier supports
+// the media type of the file, the file is minified while copying
+func (e Engine) copyAsset(srcFS fs.FS, path, outPath string, minify bool) error {
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return fmt.Errorf("assets copy: create dir: %w", err)
+	}
+
+	src, err := srcFS.Open(path)
+	if err != nil {
+		return fmt.Errorf("assets copy: open src: %w", err)
+	}
+	defer src.Close()
+
+	dst, err := fsys.CreateFresh(outPath)
+	if err != nil {
+		return fmt.Errorf("assets copy: open dst: %w", err)
+	}
+
+	defer dst.Close()
+
+	if _, params, m := e.minifier.Match(mime.TypeByExtension(filepath.Ext(path))); minify && m != nil {
+		if err := m.Minify(e.minifier, dst, src, params); err != nil {
+			return fmt.Errorf("assets minify: %s -> %s: %w", path, outPath, err)
+		}
+	} else {
+		if _, err := io.Copy(dst, src); err != nil {
+			return fmt.Errorf("assets copy: %s -> %s: %w", path, outPath, err)
+		}
+	}
+
+	return nil
 }
 
 // generates CSS styles with the given theme or fallback and write them to the writer
@@ -420,3 +787,42 @@ func (ee Errors) Error() string {
 	}
 	return buf.String()
 }
+
+// allow errors.Is and errors.As to inspect each of the errors
+func (ee Errors) Unwrap() []error {
+	return ee
+}
+
+// the error returned when a node fails to render. It records the source path
+// of the node and, if the failure happened while executing a template, the
+// name of that template and the location in form of file:line:col
+type RenderError struct {
+	SourcePath string
+	Template   string
+	Location   string
+	Err        error
+}
+
+func newRenderError(node *TreeNode, err error) *RenderError {
+	re := &RenderError{SourcePath: node.SourcePath, Err: err}
+	var execErr template.ExecError
+	if errors.As(err, &execErr) {
+		re.Template = execErr.Name
+		// exec errors are formatted as "template: file:line:col: message"
+		if loc, _, ok := strings.Cut(strings.TrimPrefix(execErr.Error(), "template: "), ": "); ok {
+			re.Location = loc
+		}
+	}
+	return re
+}
+
+func (re *RenderError) Error() string {
+	if re.Location != "" {
+		return fmt.Sprintf("render %s at %s: %s", re.SourcePath, re.Location, re.Err)
+	}
+	return fmt.Sprintf("render %s: %s", re.SourcePath, re.Err)
+}
+
+func (re *RenderError) Unwrap() error {
+	return re.Err
+}
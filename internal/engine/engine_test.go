@@ -0,0 +1,273 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var testTheme = fstest.MapFS{
+	"templates/layouts/base.html": {Data: []byte(`{{ template "main" . }}`)},
+	"templates/layouts/dir.html":  {Data: []byte(`{{ define "main" }}{{ .Title }}{{ range .Children }} {{ .Title }}{{ end }}{{ end }}`)},
+	"templates/layouts/file.html": {Data: []byte(`{{ define "main" }}{{ .Content | render }}{{ end }}`)},
+}
+
+// write the files relative to the dir, creating parent dirs as needed
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// stat the files relative to the dir
+func statFiles(t *testing.T, dir string, names []string) map[string]os.FileInfo {
+	t.Helper()
+	infos := make(map[string]os.FileInfo, len(names))
+	for _, name := range names {
+		info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		infos[name] = info
+	}
+	return infos
+}
+
+// build the tree of the docs, with the site of an engine with the options,
+// and sort it like a build does
+func testTree(t *testing.T, docs fstest.MapFS, options ...Option) *TreeNode {
+	t.Helper()
+	root, err := buildTree(docs, New(options...).site)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root.SortDefault()
+}
+
+// find the node with the source path in the tree
+func findNode(t *testing.T, root *TreeNode, p string) *TreeNode {
+	t.Helper()
+	if root.SourcePath == p {
+		return root
+	}
+	for _, c := range root.Children {
+		if strings.HasPrefix(p, c.SourcePath) {
+			if n := findNode(t, c, p); n != nil {
+				return n
+			}
+		}
+	}
+	if root.IsRoot {
+		t.Fatalf("no node %s", p)
+	}
+	return nil
+}
+
+func TestIncrementalBuild(t *testing.T) {
+	src := t.TempDir()
+	dist := filepath.Join(t.TempDir(), "dist")
+
+	writeFiles(t, src, map[string]string{
+		"docs/index.md":                  "# Home\n",
+		"docs/blog/2024-01-01-first.md":  "# First\n\nhello\n",
+		"docs/blog/2024-01-02-second.md": "# Second\n",
+		"docs/about/2024-01-03-team.md":  "# Team\n",
+		"docs/blog/diagram.png":          "v1",
+	})
+
+	e := New(WithSource(src), WithDist(dist), WithThemeFS(testTheme), WithJobs(2))
+	build := func() {
+		t.Helper()
+		if err := e.Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pages := []string{
+		"index.html",
+		"blog/index.html",
+		"blog/first/index.html",
+		"blog/second/index.html",
+		"about/index.html",
+		"about/team/index.html",
+	}
+
+	// assert which pages have been written by the last build. Pages that are
+	// carried over from the previous build are hard links to the same file
+	assertRendered := func(before map[string]os.FileInfo, want ...string) {
+		t.Helper()
+		rendered := make(map[string]bool)
+		for _, name := range want {
+			rendered[name] = true
+		}
+		after := statFiles(t, dist, pages)
+		for _, name := range pages {
+			if got := !os.SameFile(before[name], after[name]); got != rendered[name] {
+				t.Errorf("%s: rendered = %v, want %v", name, got, rendered[name])
+			}
+		}
+	}
+
+	build()
+	before := statFiles(t, dist, pages)
+
+	// nothing changed, nothing is rendered
+	build()
+	assertRendered(before)
+
+	// a content change renders the page and the dirs above it, which list it
+	before = statFiles(t, dist, pages)
+	writeFiles(t, src, map[string]string{"docs/blog/2024-01-01-first.md": "# First\n\nhello again\n"})
+	build()
+	assertRendered(before, "index.html", "blog/index.html", "blog/first/index.html")
+	b, err := os.ReadFile(filepath.Join(dist, "blog/first/index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "hello again"; !strings.Contains(string(b), want) {
+		t.Errorf("blog/first/index.html = %q, want it to contain %q", b, want)
+	}
+
+	// a title change affects the structure of the site, so every page renders
+	before = statFiles(t, dist, pages)
+	writeFiles(t, src, map[string]string{"docs/blog/2024-01-02-second.md": "# Second Post\n"})
+	build()
+	assertRendered(before, pages...)
+
+	// a resource change renders the page of its dir and the dirs above it
+	before = statFiles(t, dist, pages)
+	writeFiles(t, src, map[string]string{"docs/blog/diagram.png": "v2"})
+	build()
+	assertRendered(before, "index.html", "blog/index.html")
+
+	// a front matter change renders every page, since any page may show it
+	before = statFiles(t, dist, pages)
+	writeFiles(t, src, map[string]string{"docs/blog/2024-01-02-second.md": "---\ntags: [go]\n---\n# Second Post\n"})
+	build()
+	assertRendered(before, pages...)
+
+	// a date change, too
+	before = statFiles(t, dist, pages)
+	if err := os.Rename(
+		filepath.Join(src, "docs/about/2024-01-03-team.md"),
+		filepath.Join(src, "docs/about/2024-01-04-team.md"),
+	); err != nil {
+		t.Fatal(err)
+	}
+	build()
+	assertRendered(before, pages...)
+
+	// removing a source prunes its page, and files the build did not produce
+	writeFiles(t, dist, map[string]string{"stray.html": "", "old-post/index.html": ""})
+	if err := os.Remove(filepath.Join(src, "docs/blog/2024-01-02-second.md")); err != nil {
+		t.Fatal(err)
+	}
+	build()
+	for _, name := range []string{"blog/second/index.html", "blog/second", "stray.html", "old-post"} {
+		if _, err := os.Stat(filepath.Join(dist, filepath.FromSlash(name))); !os.IsNotExist(err) {
+			t.Errorf("%s: expected to be pruned, got %v", name, err)
+		}
+	}
+	pages = []string{"index.html", "blog/index.html", "blog/first/index.html", "about/index.html", "about/team/index.html"}
+	before = statFiles(t, dist, pages)
+
+	// without a manifest, the site is built from scratch
+	if err := os.Remove(filepath.Join(dist, ManifestName)); err != nil {
+		t.Fatal(err)
+	}
+	build()
+	assertRendered(before, pages...)
+}
+
+func TestDrafts(t *testing.T) {
+	src := t.TempDir()
+	writeFiles(t, src, map[string]string{
+		"docs/index.md":         "# Home\n",
+		"docs/notes.md":         "# Notes\n",
+		"docs/_notes.md":        "# Draft Notes\n",
+		"docs/diagram.png":      "png",
+		"docs/_wip-diagram.png": "png",
+	})
+
+	dist := filepath.Join(t.TempDir(), "dist")
+	e := New(WithSource(src), WithDist(dist), WithThemeFS(testTheme))
+	if err := e.Run(); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{
+		"notes/index.html":  true,
+		"diagram.png":       true,
+		"_wip-diagram.png":  false,
+		"wip-diagram.png":   false,
+		"_notes/index.html": false,
+	} {
+		_, err := os.Stat(filepath.Join(dist, filepath.FromSlash(name)))
+		if got := err == nil; got != want {
+			t.Errorf("%s: exists = %v, want %v", name, got, want)
+		}
+	}
+
+	// with drafts, the draft renders to the same path as the published file
+	e = New(WithSource(src), WithDist(dist), WithThemeFS(testTheme), WithDrafts(true))
+	err := e.Run()
+	var rerr *RenderError
+	if !errors.As(err, &rerr) || !strings.Contains(err.Error(), "both render to /notes/") {
+		t.Errorf("expected a conflict for /notes/, got %v", err)
+	}
+}
+
+func TestResourceDirs(t *testing.T) {
+	src := t.TempDir()
+	writeFiles(t, src, map[string]string{
+		"docs/index.md":               "# Home\n",
+		"docs/images/logo.png":        "png",
+		"docs/container/index.md":     "# Container\n",
+		"docs/container/post.md":      "# Post\n\n![fly](img/fly.svg)\n",
+		"docs/container/img/fly.svg":  "<svg></svg>",
+		"docs/container/img/_wip.svg": "<svg></svg>",
+	})
+
+	dist := filepath.Join(t.TempDir(), "dist")
+	e := New(WithSource(src), WithDist(dist), WithThemeFS(testTheme))
+	if err := e.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]bool{
+		"images/logo.png":            true,
+		"images/index.html":          false,
+		"container/img/fly.svg":      true,
+		"container/img/index.html":   false,
+		"container/img/_wip.svg":     false,
+		"container/post/img/fly.svg": true,
+	} {
+		_, err := os.Stat(filepath.Join(dist, filepath.FromSlash(name)))
+		if got := err == nil; got != want {
+			t.Errorf("%s: exists = %v, want %v", name, got, want)
+		}
+	}
+
+	// the dirs without markdown are not listed as children either
+	for name, want := range map[string]string{
+		"index.html":           "Home Container",
+		"container/index.html": "Container Post",
+	} {
+		b, err := os.ReadFile(filepath.Join(dist, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(b); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,405 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bluebrown/doktri/internal/fsys"
+)
+
+const (
+	rssFeedName  = "feed.xml"
+	atomFeedName = "atom.xml"
+	jsonFeedName = "feed.json"
+	// the number of items per feed, if not configured otherwise
+	defaultFeedLimit = 20
+)
+
+// the settings of the generated feeds
+type FeedOptions struct {
+	// the max number of items per feed. Zero means the default of 20
+	Limit int
+	// if true, the items contain the whole rendered post, otherwise only the
+	// excerpt
+	FullContent bool
+	// if true, every dir gets its own feeds, in addition to the root
+	Sections bool
+}
+
+// a post as it appears in the feeds, in all formats
+type feedItem struct {
+	Title   string
+	URL     string
+	Date    time.Time
+	Author  string
+	Content string
+	Tags    []string
+}
+
+// the feed of a node, listing the most recent posts below it
+type feed struct {
+	Title       string
+	Description string
+	URL         string
+	FeedURL     string
+	Author      string
+	Updated     time.Time
+	Items       []feedItem
+}
+
+// the absolute url of the web path. The path includes the context path
+// already
+func (s *Site) absURL(webPath string) string {
+	return strings.TrimSuffix(s.BaseURL, "/") + "/" + strings.TrimPrefix(webPath, "/")
+}
+
+// write the feeds of the root and, if enabled, every dir. Feeds are only
+// generated, if the site has a base url, since they require absolute urls
+func (e Engine) writeFeeds(dist string, prev, next *Manifest, root *TreeNode) error {
+	if e.site.BaseURL == "" {
+		return nil
+	}
+	var visit func(n *TreeNode) error
+	visit = func(n *TreeNode) error {
+		if n.IsLeaf {
+			return nil
+		}
+		if n.IsRoot || e.feeds.Sections {
+			if err := e.writeFeed(dist, prev, next, n); err != nil {
+				return err
+			}
+		}
+		for _, c := range n.Children {
+			if err := visit(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return visit(root)
+}
+
+// write the feeds of the dir node in all formats. Sections without posts get
+// no feeds, only the root always has them
+func (e Engine) writeFeed(dist string, prev, next *Manifest, n *TreeNode) error {
+	f, err := e.newFeed(n)
+	if err != nil {
+		return newRenderError(n, fmt.Errorf("feed: %w", err))
+	}
+	if len(f.Items) == 0 && !n.IsRoot {
+		return nil
+	}
+	outputs := []struct {
+		name   string
+		encode func(*feed) ([]byte, error)
+	}{
+		{rssFeedName, encodeRSS},
+		{atomFeedName, encodeAtom},
+		{jsonFeedName, encodeJSONFeed},
+	}
+	for _, o := range outputs {
+		b, err := o.encode(f)
+		if err != nil {
+			return newRenderError(n, fmt.Errorf("encode %s: %w", o.name, err))
+		}
+		if err := writeTracked(dist, prev, next, n.slug()+o.name, b); err != nil {
+			return newRenderError(n, err)
+		}
+	}
+	return nil
+}
+
+// collect the most recent posts below the dir node
+func (e Engine) newFeed(n *TreeNode) (*feed, error) {
+	title := n.Title()
+	if t, ok := e.meta["title"].(string); ok && n.IsRoot {
+		title = t
+	}
+	f := &feed{
+		Title:       title,
+		Description: n.Description(),
+		URL:         e.site.absURL(n.Path()),
+		FeedURL:     e.site.absURL(n.Path() + rssFeedName),
+		Author:      n.Author(),
+	}
+
+	var leafs TreeNodeList
+	var collect func(n *TreeNode)
+	collect = func(n *TreeNode) {
+		for _, c := range n.Children {
+			if c.IsDraft() {
+				continue
+			}
+			if c.IsLeaf {
+				leafs = append(leafs, c)
+			}
+			collect(c)
+		}
+	}
+	collect(n)
+
+	leafs = leafs.sortBy(SortByDate, SortDirectionDescending)
+	limit := e.feeds.Limit
+	if limit <= 0 {
+		limit = defaultFeedLimit
+	}
+	if len(leafs) > limit {
+		leafs = leafs[:limit]
+	}
+
+	for _, l := range leafs {
+		date, err := l.Date()
+		if err != nil {
+			return nil, err
+		}
+		doc, err := l.document()
+		if err != nil {
+			return nil, err
+		}
+		var content string
+		if e.feeds.FullContent {
+			content, err = doc.HTML()
+		} else {
+			content, err = doc.Excerpt()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", l.SourcePath, err)
+		}
+		f.Items = append(f.Items, feedItem{
+			Title:   l.Title(),
+			URL:     e.site.absURL(l.Path()),
+			Date:    date,
+			Author:  l.Author(),
+			Content: content,
+			Tags:    l.Tags(),
+		})
+		if date.After(f.Updated) {
+			f.Updated = date
+		}
+	}
+
+	// a feed without posts was last updated when its dir was, or if that is
+	// unknown, by this build
+	if f.Updated.IsZero() {
+		if mod, err := n.LastModified(); err == nil && !mod.IsZero() {
+			f.Updated = mod
+		} else {
+			f.Updated = e.site.buildTime
+		}
+	}
+
+	return f, nil
+}
+
+// write the file, without truncating a hard linked file of the previous build
+func writeOutput(p string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	f, err := fsys.CreateFresh(p)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	SelfLink      atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        string   `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Author      string   `xml:"dc:creator,omitempty"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+}
+
+func encodeRSS(f *feed) ([]byte, error) {
+	ch := rssChannel{
+		Title:       f.Title,
+		Link:        f.URL,
+		Description: f.Description,
+		SelfLink:    atomLink{Href: f.FeedURL, Rel: "self", Type: "application/rss+xml"},
+	}
+	if ch.Description == "" {
+		ch.Description = f.Title
+	}
+	if !f.Updated.IsZero() {
+		ch.LastBuildDate = f.Updated.Format(time.RFC1123Z)
+	}
+	for _, it := range f.Items {
+		ch.Items = append(ch.Items, rssItem{
+			Title:       it.Title,
+			Link:        it.URL,
+			GUID:        it.URL,
+			PubDate:     it.Date.Format(time.RFC1123Z),
+			Author:      it.Author,
+			Categories:  it.Tags,
+			Description: it.Content,
+		})
+	}
+	b, err := xml.MarshalIndent(rss{Version: "2.0", Atom: "http://www.w3.org/2005/Atom", Channel: ch}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	// the dc namespace is declared by hand, since encoding/xml does not
+	// support prefixed namespaces
+	b = bytes.Replace(b, []byte(`<rss version="2.0"`), []byte(`<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/"`), 1)
+	return append([]byte(xml.Header), b...), nil
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  *atomAuthor `xml:"author,omitempty"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     *atomAuthor    `xml:"author,omitempty"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomContent    `xml:"content"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+func encodeAtom(f *feed) ([]byte, error) {
+	af := atomFeed{
+		Title:   f.Title,
+		ID:      f.URL,
+		Updated: f.Updated.Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: f.URL, Rel: "alternate", Type: "text/html"},
+			{Href: strings.TrimSuffix(f.FeedURL, rssFeedName) + atomFeedName, Rel: "self", Type: "application/atom+xml"},
+		},
+	}
+	if f.Author != "" {
+		af.Author = &atomAuthor{Name: f.Author}
+	}
+	for _, it := range f.Items {
+		entry := atomEntry{
+			Title:     it.Title,
+			ID:        it.URL,
+			Link:      atomLink{Href: it.URL, Rel: "alternate", Type: "text/html"},
+			Published: it.Date.Format(time.RFC3339),
+			Updated:   it.Date.Format(time.RFC3339),
+			Content:   atomContent{Type: "html", Body: it.Content},
+		}
+		if it.Author != "" {
+			entry.Author = &atomAuthor{Name: it.Author}
+		}
+		for _, t := range it.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: t})
+		}
+		af.Entries = append(af.Entries, entry)
+	}
+	b, err := xml.MarshalIndent(af, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+// the json feed, version 1.1. See https://www.jsonfeed.org/version/1.1/
+type jsonFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url"`
+	FeedURL     string           `json:"feed_url"`
+	Description string           `json:"description,omitempty"`
+	Authors     []jsonFeedAuthor `json:"authors,omitempty"`
+	Items       []jsonFeedItem   `json:"items"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url"`
+	Title         string           `json:"title"`
+	ContentHTML   string           `json:"content_html"`
+	DatePublished string           `json:"date_published"`
+	Authors       []jsonFeedAuthor `json:"authors,omitempty"`
+	Tags          []string         `json:"tags,omitempty"`
+}
+
+func encodeJSONFeed(f *feed) ([]byte, error) {
+	jf := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.URL,
+		FeedURL:     strings.TrimSuffix(f.FeedURL, rssFeedName) + jsonFeedName,
+		Description: f.Description,
+		Items:       make([]jsonFeedItem, 0, len(f.Items)),
+	}
+	if f.Author != "" {
+		jf.Authors = []jsonFeedAuthor{{Name: f.Author}}
+	}
+	for _, it := range f.Items {
+		item := jsonFeedItem{
+			ID:            it.URL,
+			URL:           it.URL,
+			Title:         it.Title,
+			ContentHTML:   it.Content,
+			DatePublished: it.Date.Format(time.RFC3339),
+			Tags:          it.Tags,
+		}
+		if it.Author != "" {
+			item.Authors = []jsonFeedAuthor{{Name: it.Author}}
+		}
+		jf.Items = append(jf.Items, item)
+	}
+	return json.MarshalIndent(jf, "", "  ")
+}
@@ -0,0 +1,198 @@
+package engine
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFeed() *feed {
+	date := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	return &feed{
+		Title:   "Blog",
+		URL:     "https://example.com/blog/",
+		FeedURL: "https://example.com/blog/feed.xml",
+		Author:  "Jane Doe",
+		Updated: date,
+		Items: []feedItem{
+			{Title: "Second", URL: "https://example.com/blog/second/", Date: date, Author: "John Doe", Content: "<p>2 &lt; 3</p>", Tags: []string{"go", "web"}},
+			{Title: "First", URL: "https://example.com/blog/first/", Date: date.AddDate(0, 0, -1), Content: "<p>first</p>"},
+		},
+	}
+}
+
+func TestEncodeRSS(t *testing.T) {
+	b, err := encodeRSS(testFeed())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`xmlns:dc="http://purl.org/dc/elements/1.1/"`,
+		`<link>https://example.com/blog/</link>`,
+		`<atom:link href="https://example.com/blog/feed.xml" rel="self" type="application/rss+xml"></atom:link>`,
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("missing %s:\n%s", want, b)
+		}
+	}
+
+	var got struct {
+		Channel struct {
+			Title         string `xml:"title"`
+			Description   string `xml:"description"`
+			LastBuildDate string `xml:"lastBuildDate"`
+			Items         []struct {
+				Title       string   `xml:"title"`
+				GUID        string   `xml:"guid"`
+				PubDate     string   `xml:"pubDate"`
+				Creator     string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+				Categories  []string `xml:"category"`
+				Description string   `xml:"description"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	if err := xml.Unmarshal(b, &got); err != nil {
+		t.Fatalf("%v:\n%s", err, b)
+	}
+
+	ch := got.Channel
+	if ch.Title != "Blog" || ch.Description != "Blog" {
+		t.Errorf("channel = %+v", ch)
+	}
+	if ch.LastBuildDate != "Tue, 02 Jan 2024 03:04:05 +0000" {
+		t.Errorf("lastBuildDate = %q", ch.LastBuildDate)
+	}
+	if len(ch.Items) != 2 {
+		t.Fatalf("got %d items, want 2", len(ch.Items))
+	}
+	it := ch.Items[0]
+	if it.Title != "Second" || it.GUID != "https://example.com/blog/second/" || it.Creator != "John Doe" {
+		t.Errorf("item = %+v", it)
+	}
+	if it.Description != "<p>2 &lt; 3</p>" {
+		t.Errorf("description = %q", it.Description)
+	}
+	if strings.Join(it.Categories, ",") != "go,web" {
+		t.Errorf("categories = %q", it.Categories)
+	}
+	if ch.Items[1].Creator != "" {
+		t.Errorf("creator = %q, want none", ch.Items[1].Creator)
+	}
+}
+
+func TestEncodeAtom(t *testing.T) {
+	b, err := encodeAtom(testFeed())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got atomFeed
+	if err := xml.Unmarshal(b, &got); err != nil {
+		t.Fatalf("%v:\n%s", err, b)
+	}
+	if got.XMLName.Space != "http://www.w3.org/2005/Atom" {
+		t.Errorf("namespace = %q", got.XMLName.Space)
+	}
+	if got.Title != "Blog" || got.ID != "https://example.com/blog/" || got.Updated != "2024-01-02T03:04:05Z" {
+		t.Errorf("feed = %+v", got)
+	}
+	if len(got.Links) != 2 || got.Links[1].Href != "https://example.com/blog/atom.xml" || got.Links[1].Rel != "self" {
+		t.Errorf("links = %+v", got.Links)
+	}
+	if got.Author == nil || got.Author.Name != "Jane Doe" {
+		t.Errorf("author = %+v", got.Author)
+	}
+	if len(got.Entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(got.Entries))
+	}
+	e := got.Entries[1]
+	if e.Title != "First" || e.Published != "2024-01-01T03:04:05Z" || e.Content.Type != "html" || e.Content.Body != "<p>first</p>" {
+		t.Errorf("entry = %+v", e)
+	}
+	if e.Author != nil {
+		t.Errorf("author = %+v, want none", e.Author)
+	}
+}
+
+func TestEncodeJSONFeed(t *testing.T) {
+	b, err := encodeJSONFeed(testFeed())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got jsonFeed
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("%v:\n%s", err, b)
+	}
+	if got.Version != "https://jsonfeed.org/version/1.1" || got.FeedURL != "https://example.com/blog/feed.json" {
+		t.Errorf("feed = %+v", got)
+	}
+	if len(got.Items) != 2 {
+		t.Fatalf("got %d items, want 2", len(got.Items))
+	}
+	it := got.Items[0]
+	if it.ID != "https://example.com/blog/second/" || it.DatePublished != "2024-01-02T03:04:05Z" || it.ContentHTML != "<p>2 &lt; 3</p>" {
+		t.Errorf("item = %+v", it)
+	}
+	if len(it.Authors) != 1 || it.Authors[0].Name != "John Doe" {
+		t.Errorf("authors = %+v", it.Authors)
+	}
+
+	// an empty feed has an empty list of items, rather than null
+	b, err = encodeJSONFeed(&feed{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"items": []`) {
+		t.Errorf("empty feed:\n%s", b)
+	}
+}
+
+func TestEmptyFeeds(t *testing.T) {
+	src := t.TempDir()
+	writeFiles(t, src, map[string]string{
+		"docs/index.md":                "# Home\n",
+		"docs/blog/2024-01-01-post.md": "# Post\n",
+		"docs/about/index.md":          "# About\n",
+	})
+
+	dist := filepath.Join(t.TempDir(), "dist")
+	e := New(WithSource(src), WithDist(dist), WithThemeFS(testTheme),
+		WithBaseURL("https://example.com"), WithFeeds(FeedOptions{Sections: true}))
+	if err := e.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]bool{
+		"feed.xml":       true,
+		"blog/feed.xml":  true,
+		"blog/atom.xml":  true,
+		"blog/feed.json": true,
+		"about/feed.xml": false,
+		"about/atom.xml": false,
+	} {
+		_, err := os.Stat(filepath.Join(dist, filepath.FromSlash(name)))
+		if got := err == nil; got != want {
+			t.Errorf("%s: exists = %v, want %v", name, got, want)
+		}
+	}
+
+	// the root always has feeds, even without posts
+	if err := os.RemoveAll(filepath.Join(src, "docs/blog")); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Run(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(dist, "atom.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "0001-01-01") {
+		t.Errorf("empty feed has a zero timestamp:\n%s", b)
+	}
+}
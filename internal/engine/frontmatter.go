@@ -0,0 +1,119 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// the well known keys of the front matter of a markdown file. The front matter
+// is parsed for every node with content, and available as .Front. Only if the
+// front matter mode of the site is enabled, its values override the values
+// derived from the file name and the _dir.yaml files
+type FrontMatter struct {
+	// overrides the title
+	Title string
+	// overrides the date
+	Date time.Time
+	// overrides the author
+	Author string
+	// tags of the node
+	Tags []string
+	// marks the node as draft
+	Draft bool
+	// keeps the node out of the sitemap and search engines
+	NoIndex bool
+	// overrides the layout
+	Layout string
+	// merged into the params of the _dir.yaml files
+	Params map[string]any
+	// all keys of the front matter, as they have been parsed
+	Raw map[string]any
+}
+
+// return the parsed front matter of the node. It is empty, if the node has no
+// content, or its content has no front matter. The front matter is decoded
+// once and then cached
+func (n *TreeNode) Front() (*FrontMatter, error) {
+	n.cache.frontOnce.Do(func() {
+		n.cache.front = &FrontMatter{}
+		if n.Site.markdown == nil || (!n.IsLeaf && !n.HasIndex()) {
+			return
+		}
+		doc, err := n.document()
+		if err != nil {
+			n.cache.frontErr = err
+			return
+		}
+		var raw map[string]any
+		ok, err := doc.DecodeFront(&raw)
+		if err != nil {
+			n.cache.frontErr = fmt.Errorf("front matter: %w", err)
+			return
+		}
+		if ok {
+			n.cache.front = newFrontMatter(raw)
+		}
+	})
+	return n.cache.front, n.cache.frontErr
+}
+
+// the front matter of the node, for deriving other values. If it cannot be
+// decoded, it is empty, and the error is reported when the tree is
+// fingerprinted
+func (n *TreeNode) front() *FrontMatter {
+	fm, _ := n.Front()
+	return fm
+}
+
+// the front matter is only used to override node values, if the site opted
+// into it
+func (n *TreeNode) useFront() bool {
+	return n.Site.FrontMatter
+}
+
+func newFrontMatter(raw map[string]any) *FrontMatter {
+	fm := &FrontMatter{Raw: raw}
+	fm.Title, _ = raw["title"].(string)
+	fm.Author, _ = raw["author"].(string)
+	fm.Layout, _ = raw["layout"].(string)
+	fm.Draft, _ = raw["draft"].(bool)
+	fm.NoIndex, _ = raw["noindex"].(bool)
+	if t, ok := parseFrontMatterDate(raw["date"]); ok {
+		fm.Date = t
+	}
+	switch tags := raw["tags"].(type) {
+	case []any:
+		for _, t := range tags {
+			fm.Tags = append(fm.Tags, fmt.Sprint(t))
+		}
+	case string:
+		for _, t := range strings.Split(tags, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				fm.Tags = append(fm.Tags, t)
+			}
+		}
+	}
+	if params, ok := raw["params"].(map[string]any); ok {
+		fm.Params = params
+	}
+	return fm
+}
+
+// parse the date of the front matter. Yaml timestamps are decoded as time
+// already, all other values are parsed with the date layouts
+func parseFrontMatterDate(v any) (time.Time, bool) {
+	switch v := v.(type) {
+	case nil:
+		return time.Time{}, false
+	case time.Time:
+		return v, true
+	}
+	s := strings.TrimSpace(fmt.Sprint(v))
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -1,12 +1,8 @@
 package engine
 
 import (
-	"bytes"
 	"fmt"
 	"text/template"
-
-	"github.com/yuin/goldmark/text"
-	"go.abhg.dev/goldmark/toc"
 )
 
 // closes over the engine to provide specialized functions that can be used
@@ -41,70 +37,26 @@ func (fmc *FuncMapClosure) Meta() func() map[string]any {
 }
 
 // convert the given raw markdown bytes to html
-func (fmc *FuncMapClosure) Render() func(b []byte) string {
-	return func(b []byte) string {
-		var buf bytes.Buffer
-		if err := fmc.e.markdown.Convert(b, &buf); err != nil {
-			panic(err)
-		}
-		return buf.String()
+func (fmc *FuncMapClosure) Render() func(b []byte) (string, error) {
+	return func(b []byte) (string, error) {
+		return fmc.e.site.document(b).HTML()
 	}
 }
 
 // generate a table of contents from the raw markdown bytes. the toc is returned
 // as html ul element
-func (fmc *FuncMapClosure) Toc() func(b []byte) string {
-	return func(b []byte) string {
-		doc := fmc.e.markdown.Parser().Parse(text.NewReader(b))
-		tree, err := toc.Inspect(doc, b)
-		if err != nil {
-			panic(err)
-		}
-		list := toc.RenderList(tree)
-
-		// the first child is the first list item
-		n := list.FirstChild()
-		if n == nil {
-			return ""
-		}
-
-		// the first child of that is the anchor
-		n = n.FirstChild()
-		if n == nil {
-			return ""
-		}
-
-		// the sibling of that is the nested ul
-		n = n.NextSibling()
-		if n == nil {
-			return ""
-		}
-
-		buf := new(bytes.Buffer)
-		if err := fmc.e.markdown.Renderer().Render(buf, b, n); err != nil {
-			panic(err)
-		}
-
-		return buf.String()
+func (fmc *FuncMapClosure) Toc() func(b []byte) (string, error) {
+	return func(b []byte) (string, error) {
+		return fmc.e.site.document(b).TOC()
 	}
 }
 
 // generate an expert in form of an html paragraph. the paragraph will be the
 // first paragraph of the raw markdown. if the markdown has no paragraphs, the
 // returned string is empty
-func (fmc *FuncMapClosure) Excerpt() func(b []byte) string {
-	return func(b []byte) string {
-		// TODO: find out if paragraph parser can be used
-		doc := fmc.e.markdown.Parser().Parse(text.NewReader(b))
-		firstParagraph := doc.FirstChild().NextSibling()
-		if firstParagraph == nil {
-			return ""
-		}
-		buf := new(bytes.Buffer)
-		if err := fmc.e.markdown.Renderer().Render(buf, b, firstParagraph); err != nil {
-			panic(err)
-		}
-		return buf.String()
+func (fmc *FuncMapClosure) Excerpt() func(b []byte) (string, error) {
+	return func(b []byte) (string, error) {
+		return fmc.e.site.document(b).Excerpt()
 	}
 }
 
@@ -114,7 +66,7 @@ func (fmc *FuncMapClosure) Excerpt() func(b []byte) string {
 // assets in your templates
 func (fmc *FuncMapClosure) Link() func(href, rel string) string {
 	return func(href, rel string) string {
-		return fmt.Sprintf(`<link rel="%s" href="%s%s/%s">`, rel, CONTEXT_PATH, "assets", href)
+		return fmt.Sprintf(`<link rel="%s" href="%s%s/%s">`, rel, fmc.e.site.ContextPath, "assets", href)
 	}
 }
 
@@ -122,8 +74,6 @@ func (fmc *FuncMapClosure) Link() func(href, rel string) string {
 // returned as a map[string]any. The keys are the names of the front matter
 func (fmc *FuncMapClosure) FrontMatter() func(b []byte) map[string]any {
 	return func(b []byte) map[string]any {
-		root := fmc.e.markdown.Parser().Parse(text.NewReader(b))
-		doc := root.OwnerDocument()
-		return doc.Meta()
+		return fmc.e.site.document(b).Meta()
 	}
 }
@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"bytes"
+	"io/fs"
+	"path"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// the names of the files providing the content of their dir, in order of
+// precedence. If a dir has both, the README.md is a regular leaf
+var indexNames = []string{"index.md", "README.md"}
+
+// find the index file of the dir. The result is empty, if it has none
+func findIndex(fsys fs.FS, dir string) string {
+	for _, name := range indexNames {
+		p := path.Join(dir, name)
+		if info, err := fs.Stat(fsys, p); err == nil && info.Mode().IsRegular() {
+			return p
+		}
+	}
+	return ""
+}
+
+// return true if the node is a dir with an index.md or README.md. Its
+// content, title, date, excerpt and toc are taken from that file
+func (n *TreeNode) HasIndex() bool {
+	return n.indexPath != ""
+}
+
+// the path of the file holding the content of the node within the source fs.
+// For leafs, this is the source file itself, for dirs the index file. It is
+// empty for dirs without index
+func (n *TreeNode) contentPath() string {
+	if n.IsLeaf {
+		return n.SourcePath
+	}
+	return n.indexPath
+}
+
+// concatenate the text of all descendants of the node, dropping any markup
+func plainText(node ast.Node, source []byte) string {
+	var buf bytes.Buffer
+	ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch t := n.(type) {
+		case *ast.Text:
+			buf.Write(t.Segment.Value(source))
+			if t.SoftLineBreak() || t.HardLineBreak() {
+				buf.WriteByte(' ')
+			}
+		case *ast.String:
+			buf.Write(t.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	return buf.String()
+}
@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestDirIndex(t *testing.T) {
+	tests := []struct {
+		name         string
+		files        []string
+		wantIndex    bool
+		wantContent  string
+		wantChildren []string
+	}{
+		{
+			name:         "index.md",
+			files:        []string{"index.md", "post.md"},
+			wantIndex:    true,
+			wantContent:  "# index.md\n",
+			wantChildren: []string{"post"},
+		},
+		{
+			name:         "README.md",
+			files:        []string{"README.md", "post.md"},
+			wantIndex:    true,
+			wantContent:  "# README.md\n",
+			wantChildren: []string{"post"},
+		},
+		{
+			name:         "index.md before README.md",
+			files:        []string{"README.md", "index.md"},
+			wantIndex:    true,
+			wantContent:  "# index.md\n",
+			wantChildren: []string{"README"},
+		},
+		{
+			name:         "no index",
+			files:        []string{"post.md"},
+			wantIndex:    false,
+			wantContent:  "",
+			wantChildren: []string{"post"},
+		},
+		{
+			name:         "other names are files",
+			files:        []string{"Index.md", "readme.md"},
+			wantIndex:    false,
+			wantContent:  "",
+			wantChildren: []string{"Index", "readme"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docs := fstest.MapFS{}
+			for _, name := range tt.files {
+				docs["section/"+name] = &fstest.MapFile{Data: []byte("# " + name + "\n")}
+			}
+			// the root takes its content from its own index as well
+			docs["README.md"] = &fstest.MapFile{Data: []byte("# Home\n")}
+			root := testTree(t, docs)
+			if !root.HasIndex() || root.Title() != "Home" {
+				t.Errorf("root: index = %v, title = %q", root.HasIndex(), root.Title())
+			}
+
+			section := findNode(t, root, "section")
+			if got := section.HasIndex(); got != tt.wantIndex {
+				t.Errorf("HasIndex = %v, want %v", got, tt.wantIndex)
+			}
+			content, err := section.Content()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(content); got != tt.wantContent {
+				t.Errorf("Content = %q, want %q", got, tt.wantContent)
+			}
+			var children []string
+			for _, c := range section.Children {
+				children = append(children, c.Name())
+			}
+			if !reflect.DeepEqual(children, tt.wantChildren) {
+				t.Errorf("children = %v, want %v", children, tt.wantChildren)
+			}
+		})
+	}
+}
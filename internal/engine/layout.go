@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"io/fs"
+	"path"
+	"text/template"
+)
+
+// the layouts resolve the template to render a node with, and cache the
+// templates, so each is only parsed once per build
+type layouts struct {
+	fsys  fs.FS
+	parse func(name string) (*template.Template, error)
+	cache map[string]*template.Template
+}
+
+func newLayouts(fsys fs.FS, parse func(name string) (*template.Template, error)) *layouts {
+	return &layouts{fsys: fsys, parse: parse, cache: make(map[string]*template.Template)}
+}
+
+// get the template for the node, parsing it on first use
+func (l *layouts) Get(n *TreeNode) (*template.Template, error) {
+	name := l.Name(n)
+	if t, ok := l.cache[name]; ok {
+		return t, nil
+	}
+	t, err := l.parse(name)
+	if err != nil {
+		return nil, err
+	}
+	l.cache[name] = t
+	return t, nil
+}
+
+// resolve the name of the layout for the node. The first of the following
+// layouts, that exists in the theme, is used:
+//   - the layout of the front matter, if the front matter mode is enabled
+//   - the layout named in the _dir.yaml of the dir or one of its ancestors,
+//     if the node is a dir
+//   - home, if the node is the root
+//   - <section>/dir or <section>/file, where section is the dir of the node
+//     within docs. Each parent of the section is tried as well
+//   - dir or file
+func (l *layouts) Name(n *TreeNode) string {
+	if n.useFront() && n.front().Layout != "" && l.exists(n.front().Layout) {
+		return n.front().Layout
+	}
+
+	kind := "file"
+	section := path.Dir(n.SourcePath)
+	if !n.IsLeaf {
+		kind = "dir"
+		section = n.SourcePath
+		if n.config.Layout != "" && l.exists(n.config.Layout) {
+			return n.config.Layout
+		}
+	}
+
+	if n.IsRoot && l.exists("home") {
+		return "home"
+	}
+
+	for ; section != "."; section = path.Dir(section) {
+		if name := path.Join(section, kind); l.exists(name) {
+			return name
+		}
+	}
+
+	return kind
+}
+
+func (l *layouts) exists(name string) bool {
+	_, err := fs.Stat(l.fsys, path.Join(themeLayoutsDir, name+".html"))
+	return err == nil
+}
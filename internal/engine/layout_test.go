@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestLayoutName(t *testing.T) {
+	theme := fstest.MapFS{
+		"templates/layouts/home.html":      {},
+		"templates/layouts/dir.html":       {},
+		"templates/layouts/file.html":      {},
+		"templates/layouts/list.html":      {},
+		"templates/layouts/custom.html":    {},
+		"templates/layouts/blog/dir.html":  {},
+		"templates/layouts/blog/file.html": {},
+	}
+	docs := fstest.MapFS{
+		"index.md":               {Data: []byte("---\nlayout: custom\n---\n# Home\n")},
+		"about.md":               {Data: []byte("# About\n")},
+		"blog/post.md":           {Data: []byte("# Post\n")},
+		"blog/custom.md":         {Data: []byte("---\nlayout: custom\n---\n# Custom\n")},
+		"blog/missing.md":        {Data: []byte("---\nlayout: missing\n---\n# Missing\n")},
+		"blog/2024/deep.md":      {Data: []byte("# Deep\n")},
+		"news/_dir.yaml":         {Data: []byte("layout: list\n")},
+		"news/item.md":           {Data: []byte("# Item\n")},
+		"news/archive/old.md":    {Data: []byte("# Old\n")},
+		"links/_dir.yaml":        {Data: []byte("layout: missing\n")},
+		"links/link.md":          {Data: []byte("# Link\n")},
+		"blog/listed/_dir.yaml":  {Data: []byte("layout: list\n")},
+		"blog/listed/listed.md":  {Data: []byte("# Listed\n")},
+		"blog/listed/custom.md":  {Data: []byte("---\nlayout: custom\n---\n# Custom\n")},
+		"blog/2024/12/_dir.yaml": {Data: []byte("layout: custom\n")},
+		"blog/2024/12/late.md":   {Data: []byte("# Late\n")},
+	}
+
+	tests := []struct {
+		node  string
+		front bool
+		want  string
+	}{
+		// the front matter wins, if enabled and the layout exists
+		{node: "blog/custom.md", front: true, want: "custom"},
+		{node: "blog/custom.md", front: false, want: "blog/file"},
+		{node: "blog/missing.md", front: true, want: "blog/file"},
+		{node: "blog/listed/custom.md", front: true, want: "custom"},
+		{node: ".", front: true, want: "custom"},
+		// then the _dir.yaml of dirs, which cascades
+		{node: "news", want: "list"},
+		{node: "news/archive", want: "list"},
+		{node: "blog/listed", want: "list"},
+		{node: "blog/2024/12", want: "custom"},
+		{node: "links", want: "dir"},
+		// leafs don't use the _dir.yaml layout
+		{node: "news/item.md", want: "file"},
+		{node: "blog/listed/listed.md", want: "blog/file"},
+		// then home for the root
+		{node: ".", want: "home"},
+		// then the layout of the section, or of a parent section
+		{node: "blog", want: "blog/dir"},
+		{node: "blog/post.md", want: "blog/file"},
+		{node: "blog/2024", want: "blog/dir"},
+		{node: "blog/2024/deep.md", want: "blog/file"},
+		// then the layout of the kind
+		{node: "about.md", want: "file"},
+	}
+
+	trees := map[bool]*TreeNode{
+		false: testTree(t, docs),
+		true:  testTree(t, docs, WithFrontMatter(true)),
+	}
+	l := newLayouts(theme, nil)
+	for _, tt := range tests {
+		n := findNode(t, trees[tt.front], tt.node)
+		if got := l.Name(n); got != tt.want {
+			t.Errorf("%s (front matter %v): layout = %q, want %q", tt.node, tt.front, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/bluebrown/doktri/internal/fsys"
+)
+
+const (
+	// the name of the manifest file, relative to the dist dir
+	ManifestName = ".doktri-manifest.json"
+	// bump the version whenever the fingerprint calculation changes, so that
+	// manifests written by older versions are discarded
+	manifestVersion = 1
+)
+
+// the manifest is written into the dist dir at the end of each build. It
+// records a fingerprint for every output file, so that the next build can
+// skip outputs whose inputs did not change and prune outputs that are no
+// longer produced
+type Manifest struct {
+	Version int `json:"version"`
+	// maps the slash separated path of an output, relative to dist, to the
+	// fingerprint of the inputs used to produce it
+	Outputs map[string]string `json:"outputs"`
+}
+
+func NewManifest() *Manifest {
+	return &Manifest{Version: manifestVersion, Outputs: make(map[string]string)}
+}
+
+// read the manifest from the given dist dir. If there is no manifest, or it
+// has been written by an incompatible version, an empty manifest is returned
+func ReadManifest(dist string) (*Manifest, error) {
+	b, err := os.ReadFile(filepath.Join(dist, ManifestName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return NewManifest(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m := NewManifest()
+	if err := json.Unmarshal(b, m); err != nil || m.Version != manifestVersion || m.Outputs == nil {
+		return NewManifest(), nil
+	}
+	return m, nil
+}
+
+// write the manifest into the given dist dir
+func (m *Manifest) Write(dist string) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := fsys.CreateFresh(filepath.Join(dist, ManifestName))
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// report whether the output at the given path is up to date. That is the case
+// if the manifest has the same fingerprint and the file still exists in dist
+func (m *Manifest) Fresh(dist, out, fingerprint string) bool {
+	if m.Outputs[out] != fingerprint {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(dist, filepath.FromSlash(out)))
+	return err == nil
+}
+
+// remove all outputs of the manifest that are not part of the next manifest
+// and clean up directories that became empty as result
+func (m *Manifest) Prune(dist string, next *Manifest) ([]string, error) {
+	stale := make([]string, 0)
+	for out := range m.Outputs {
+		if _, ok := next.Outputs[out]; !ok {
+			stale = append(stale, out)
+		}
+	}
+	sort.Strings(stale)
+
+	for _, out := range stale {
+		p := filepath.Join(dist, filepath.FromSlash(out))
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("remove %s: %w", out, err)
+		}
+		// walk up and remove empty parent dirs, but never dist itself
+		for dir := filepath.Dir(p); dir != filepath.Clean(dist); dir = filepath.Dir(dir) {
+			if err := os.Remove(dir); err != nil {
+				break
+			}
+		}
+	}
+
+	return stale, nil
+}
+
+// the fingerprint is a sha256 hash over arbitrary input parts. Parts are
+// length prefixed so that different splits of the same bytes don't collide
+type fingerprint struct {
+	h hash.Hash
+}
+
+func newFingerprint() *fingerprint {
+	return &fingerprint{h: sha256.New()}
+}
+
+func (f *fingerprint) Add(parts ...[]byte) *fingerprint {
+	for _, p := range parts {
+		fmt.Fprintf(f.h, "%d:", len(p))
+		f.h.Write(p)
+	}
+	return f
+}
+
+func (f *fingerprint) AddString(parts ...string) *fingerprint {
+	for _, p := range parts {
+		f.Add([]byte(p))
+	}
+	return f
+}
+
+// add the content of the given file. Missing files are recorded as such, so
+// that creating them later changes the fingerprint
+func (f *fingerprint) AddFile(path string) error {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		f.AddString("missing", path)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	f.AddString("file", path)
+	f.Add(b)
+	return nil
+}
+
+// add the content of the named file in the given fs. Missing files are recorded
+// as such
+func (f *fingerprint) AddFS(fsys fs.FS, name string) error {
+	b, err := fs.ReadFile(fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		f.AddString("missing", name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	f.AddString("file", name)
+	f.Add(b)
+	return nil
+}
+
+func (f *fingerprint) String() string {
+	return hex.EncodeToString(f.h.Sum(nil))
+}
+
+// compute the fingerprint of each node in the tree. Since templates can
+// traverse the whole tree, every fingerprint includes the inputs shared by all
+// pages, as well as the structure of the tree, that is the path, title, date,
+// front matter, dir config and resources of each node. A leaf additionally
+// depends on its own content, last modification time and the content of its
+// resources, while a dir depends on the same of itself and all its
+// descendants, as dir pages commonly show excerpts of the pages below them.
+// Pages that show the modification time or resources of nodes other than
+// themselves and their descendants, are not rendered again when those change.
+// Use a clean build for them. Nodes whose content, date or front matter cannot
+// be read, are reported as error
+func fingerprintTree(root *TreeNode, inputs string) (map[*TreeNode]string, error) {
+	// the fingerprint of the inputs of each node itself
+	owns := make(map[*TreeNode]string)
+	structure := newFingerprint()
+
+	var visit func(n *TreeNode) error
+	visit = func(n *TreeNode) error {
+		b, err := n.Content()
+		if err != nil {
+			return newRenderError(n, err)
+		}
+		date, err := n.Date()
+		if err != nil {
+			return newRenderError(n, err)
+		}
+		if _, err := n.Front(); err != nil && n.useFront() {
+			return newRenderError(n, err)
+		}
+		mod, err := n.LastModified()
+		if err != nil {
+			return newRenderError(n, err)
+		}
+		own := newFingerprint().Add(b).AddString(mod.String())
+		for _, r := range n.resources {
+			if err := own.AddFS(n.fs, r.SourcePath); err != nil {
+				return newRenderError(n, fmt.Errorf("resource %s: %w", r.Name, err))
+			}
+		}
+		owns[n] = own.String()
+		structure.AddString(n.SourcePath, n.Path(), n.Title(), date.String(), fmt.Sprint(n.front().Raw), fmt.Sprint(n.config))
+		for _, r := range n.resources {
+			structure.AddString(r.SourcePath)
+		}
+		for _, c := range n.Children {
+			if err := visit(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := visit(root); err != nil {
+		return nil, err
+	}
+
+	s := structure.String()
+	fps := make(map[*TreeNode]string, len(owns))
+
+	var descend func(n *TreeNode, f *fingerprint)
+	descend = func(n *TreeNode, f *fingerprint) {
+		for _, c := range n.Children {
+			f.AddString(c.SourcePath, owns[c])
+			descend(c, f)
+		}
+	}
+
+	for n, own := range owns {
+		f := newFingerprint().AddString(inputs, s, n.SourcePath, own)
+		if !n.IsLeaf {
+			descend(n, f)
+		}
+		fps[n] = f.String()
+	}
+
+	return fps, nil
+}
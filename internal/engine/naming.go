@@ -0,0 +1,89 @@
+package engine
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// the naming scheme of a dir determines how the names of its entries are
+// interpreted. It is set with the naming key of the _dir.yaml
+type NamingScheme string
+
+const (
+	// entries may be prefixed with yyyy-mm-dd-. The prefix is stripped from
+	// the name of leafs and used as their date. This is the default
+	NamingDate NamingScheme = "date"
+	// entries may be prefixed with a numeric weight, i.e. 01-install.md. The
+	// prefix is stripped from the name and used for ordering
+	NamingWeight NamingScheme = "weight"
+	// entries are used by their plain name
+	NamingNone NamingScheme = "none"
+)
+
+// parse the naming scheme. An empty string is the default scheme
+func ParseNamingScheme(s string) (NamingScheme, error) {
+	switch ns := NamingScheme(s); ns {
+	case "":
+		return NamingDate, nil
+	case NamingDate, NamingWeight, NamingNone:
+		return ns, nil
+	}
+	return "", fmt.Errorf("unknown naming scheme %q, must be one of %s, %s, %s",
+		s, NamingDate, NamingWeight, NamingNone)
+}
+
+// matches the numeric prefix of weighted names
+var weightPrefix = regexp.MustCompile(`^(\d+)-`)
+
+// the naming scheme of the dir containing the node
+func (n *TreeNode) Naming() NamingScheme {
+	if n.IsRoot || n.Parent.config.Naming == "" {
+		return NamingDate
+	}
+	return n.Parent.config.Naming
+}
+
+// return the weight of the node, if its dir uses weight prefixes. Otherwise,
+// or if the name has no weight prefix, the weight is 0
+func (n *TreeNode) Weight() int {
+	if n.IsRoot || n.Naming() != NamingWeight {
+		return 0
+	}
+	m := weightPrefix.FindStringSubmatch(n.baseName())
+	if m == nil {
+		return 0
+	}
+	w, _ := strconv.Atoi(m[1])
+	return w
+}
+
+// the name of the node according to the naming scheme of its dir
+func (n *TreeNode) normalizedName() string {
+	name := n.baseName()
+	if n.IsLeaf {
+		name = strings.TrimSuffix(name, ".md")
+	}
+	switch n.Naming() {
+	case NamingDate:
+		// only leafs carry a date in their name
+		if n.IsLeaf {
+			return NormalizeMdName(name)
+		}
+	case NamingWeight:
+		if m := weightPrefix.FindString(name); m != "" {
+			return name[len(m):]
+		}
+	}
+	return name
+}
+
+// the path of the node relative to the root, as it is used on the web page.
+// It is empty for the root and ends with a slash otherwise
+func (n *TreeNode) slug() string {
+	if n.IsRoot {
+		return ""
+	}
+	return n.Parent.slug() + n.Name() + "/"
+}
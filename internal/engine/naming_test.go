@@ -0,0 +1,80 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestWeightOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		dir   string
+		files []string
+		want  []string
+	}{
+		{
+			name:  "lowest weight first",
+			dir:   "naming: weight\n",
+			files: []string{"03-usage.md", "01-install.md", "02-configure.md"},
+			want:  []string{"install", "configure", "usage"},
+		},
+		{
+			name:  "weights are numbers",
+			dir:   "naming: weight\n",
+			files: []string{"10-faq.md", "2-usage.md", "1-install.md"},
+			want:  []string{"install", "usage", "faq"},
+		},
+		{
+			name:  "dirs are weighted like files",
+			dir:   "naming: weight\n",
+			files: []string{"02-usage.md", "01-setup/linux.md", "03-api/client.md"},
+			want:  []string{"setup", "usage", "api"},
+		},
+		{
+			name:  "names without weight are weight 0",
+			dir:   "naming: weight\n",
+			files: []string{"01-install.md", "faq.md"},
+			want:  []string{"faq", "install"},
+		},
+		{
+			name:  "equal weights keep the order of the names",
+			dir:   "naming: weight\n",
+			files: []string{"01-b.md", "01-a.md", "00-c.md"},
+			want:  []string{"c", "a", "b"},
+		},
+		{
+			name:  "the sort of the dir config wins",
+			dir:   "naming: weight\nsort:\n  by: weight\n  direction: desc\n",
+			files: []string{"01-install.md", "02-configure.md", "03-usage.md"},
+			want:  []string{"usage", "configure", "install"},
+		},
+		{
+			name:  "other naming schemes keep the prefix",
+			dir:   "naming: none\nsort:\n  by: weight\n",
+			files: []string{"02-usage.md", "01-install.md"},
+			want:  []string{"01-install", "02-usage"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docs := fstest.MapFS{
+				"index.md":        {Data: []byte("# Home\n")},
+				"guide/_dir.yaml": {Data: []byte(tt.dir)},
+				"guide/README.md": {Data: []byte("# Guide\n")},
+			}
+			for _, name := range tt.files {
+				docs["guide/"+name] = &fstest.MapFile{Data: []byte("# Page\n")}
+			}
+			guide := findNode(t, testTree(t, docs), "guide")
+			var got []string
+			for _, c := range guide.Children {
+				got = append(got, c.Name())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,27 +1,22 @@
 package engine
 
 import (
+	"errors"
 	"fmt"
 	"io/fs"
-	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"time"
-
-	"golang.org/x/text/cases"
-	"golang.org/x/text/language"
-)
-
-var (
-	// the context path can be used if the page is not hosted at the domain root
-	CONTEXT_PATH = "/"
-	// the post author is used globally for all posts
-	POST_AUTHOR = "Anonymous"
 )
 
-// normalize the string by stripping the date prefix and .md suffix
+// normalize the string by stripping the date prefix, if any, and .md suffix
 func NormalizeMdName(s string) string {
-	return strings.TrimSuffix(s, ".md")[11:]
+	s = strings.TrimSuffix(s, ".md")
+	if _, ok := parseDatePrefix(s); ok {
+		return s[11:]
+	}
+	return s
 }
 
 type SortDirection string
@@ -32,12 +27,29 @@ const (
 )
 
 // the cache holds values that dont change
-// they will be set on first call of the corresponding method
+// they will be set on first call of the corresponding method.
+// Each value is guarded by its own once, so that the cache is safe
+// for concurrent use, and methods can call each other without deadlocks
 type nodeCache struct {
-	date  *time.Time
-	path  string
-	name  string
-	title string
+	dateOnce  sync.Once
+	date      time.Time
+	dateErr   error
+	modOnce   sync.Once
+	mod       time.Time
+	modErr    error
+	pathOnce  sync.Once
+	path      string
+	nameOnce  sync.Once
+	name      string
+	titleOnce sync.Once
+	title     string
+	frontOnce sync.Once
+	front     *FrontMatter
+	frontErr  error
+	// the content is read once, and kept for the duration of the build
+	contentOnce sync.Once
+	content     []byte
+	contentErr  error
 }
 
 type TreeNode struct {
@@ -58,29 +70,46 @@ type TreeNode struct {
 	// pointer to root node. will point to itself for the treeRoot
 	// this makes it more easy to use it in templates
 	Root *TreeNode
+	// the site settings, shared by all nodes of the tree
+	Site *Site
 	// the cache is an internal struct to hold values
 	// that are cached when methods are called
 	cache *nodeCache
+	// the settings of the _dir.yaml files, cascaded from the root down to
+	// the dir of the node. Leafs share the settings of their dir, except for
+	// title, description and naming
+	config DirConfig
+	// the path of the index.md or README.md, if the node is a dir that has one
+	indexPath string
+	// the non markdown files in the dir, if the node is a dir
+	resources []*Resource
 }
 
 // get the content for this node by reading the source file this is done in this
 // method so we don't need to read all files into memory, at once. Each piece of
 // content can be read lazily when its actually needed because a given template
-// wants to use it. if its not a leaf note, the content of the index.md in the
-// given dir is returned if possible. Otherwise the byte slice will have len 0
-func (n *TreeNode) Content() []byte {
-	if !n.IsLeaf {
-		b, err := fs.ReadFile(n.fs, filepath.Join(n.SourcePath, "index.md"))
-		if err != nil {
-			return []byte{}
+// wants to use it. if its not a leaf note, the content of the index.md or
+// README.md in the given dir is returned if possible. Otherwise the byte slice
+// will have len 0. The file is read on first call only
+func (n *TreeNode) Content() ([]byte, error) {
+	n.cache.contentOnce.Do(func() {
+		if !n.IsLeaf && !n.HasIndex() {
+			n.cache.content = []byte{}
+			return
 		}
-		return b
-	}
-	b, err := fs.ReadFile(n.fs, n.SourcePath)
+		n.cache.content, n.cache.contentErr = fs.ReadFile(n.fs, n.contentPath())
+	})
+	return n.cache.content, n.cache.contentErr
+}
+
+// the parsed content of the node. It is shared with the template funcs, that
+// get the same content
+func (n *TreeNode) document() (*document, error) {
+	b, err := n.Content()
 	if err != nil {
-		panic("failed to read content")
+		return nil, err
 	}
-	return b
+	return n.Site.document(b), nil
 }
 
 // return the normalized path as its used on the web page.
@@ -88,60 +117,64 @@ func (n *TreeNode) Content() []byte {
 // always point to a directory because even leaf nodes are created
 // as index.html under a directory with the leaf nodes name
 func (n *TreeNode) Path() string {
-	if n.cache.path == "" {
-		if n.IsRoot {
-			n.cache.path = CONTEXT_PATH
-		} else {
-			s := n.SourcePath
-			if n.IsLeaf {
-				s = filepath.Join(filepath.Dir(s), NormalizeMdName(filepath.Base(s)))
-			}
-			n.cache.path = CONTEXT_PATH + filepath.ToSlash(s) + "/"
-		}
-	}
+	n.cache.pathOnce.Do(func() {
+		n.cache.path = n.Site.ContextPath + n.slug()
+	})
 	return n.cache.path
 }
 
 // Return the normalized name as its used on a web page.
-// this will strip the date prefix and .md suffix
+// this will strip the .md suffix and the prefix of the
+// naming scheme of its dir, i.e. the date prefix
 func (n *TreeNode) Name() string {
-	if n.cache.name != "" {
-		return n.cache.name
-	}
-	if n.IsRoot {
-		n.cache.name = "home"
-	} else {
-		n.cache.name = filepath.Base(n.SourcePath)
-		if n.IsLeaf {
-			// remove the date prefix and .md suffix, if its a leaf
-			n.cache.name = NormalizeMdName(n.cache.name)
+	n.cache.nameOnce.Do(func() {
+		if n.IsRoot {
+			n.cache.name = "home"
+		} else {
+			n.cache.name = n.normalizedName()
 		}
-	}
+	})
 	return n.cache.name
 }
 
-// return the normalized name as human readable title.
-// dashes are replaced with spaces and the word tokens are title cased
+// return the title of the front matter, if the front matter mode is enabled.
+// Otherwise return the title set in the _dir.yaml, if the node is a dir and
+// has one. Otherwise return the first level 1 heading of the document, or of the index
+// if its a dir. Otherwise return the normalized name as human readable title.
+// dashes are replaced with spaces and the word tokens are title cased, except
+// for the acronyms of the site
 func (n *TreeNode) Title() string {
-	if n.cache.title == "" {
-		// TODO: handle abbreviations
-		// name := n.Name()
-		// if its 3 or less, its probably an abbreviation
-		// if len(name) <= 3 {
-		// 	return strings.ToUpper(name)
-		// }
-		caser := cases.Title(language.English)
-		n.cache.title = caser.String(strings.ReplaceAll(n.Name(), "-", " "))
-	}
+	n.cache.titleOnce.Do(func() {
+		if n.useFront() && n.front().Title != "" {
+			n.cache.title = n.front().Title
+			return
+		}
+		if n.config.Title != "" {
+			n.cache.title = n.config.Title
+			return
+		}
+		if n.IsLeaf || n.HasIndex() {
+			// if the content cannot be read, the name is used. The error
+			// is reported, when the content is rendered
+			if doc, err := n.document(); err == nil && doc.Heading() != "" {
+				n.cache.title = doc.Heading()
+				return
+			}
+		}
+		n.cache.title = n.Site.titleCase(n.Name())
+	})
 	return n.cache.title
 }
 
+// the error returned when the siblings of the root node are requested
+var errRootSiblings = errors.New("root node cannot have siblings")
+
 // convenience function to get a nodes siblings this is the same as getting the
-// parents children, filtering itself out. will panic when called on the root
-// node, since a root has no parent and therefore no siblings
-func (n *TreeNode) Siblings() TreeNodeList {
+// parents children, filtering itself out. returns an error when called on the
+// root node, since a root has no parent and therefore no siblings
+func (n *TreeNode) Siblings() (TreeNodeList, error) {
 	if n.IsRoot {
-		panic("root node cannot have siblings")
+		return nil, errRootSiblings
 	}
 	ss := make([]*TreeNode, 0, len(n.Parent.Children)-1)
 	for _, c := range n.Parent.Children {
@@ -150,7 +183,7 @@ func (n *TreeNode) Siblings() TreeNodeList {
 		}
 		ss = append(ss, c)
 	}
-	return ss
+	return ss, nil
 }
 
 // get the first child of this node. Returns nil of node has no children
@@ -161,11 +194,11 @@ func (n *TreeNode) FirstChild() *TreeNode {
 	return n.Children[0]
 }
 
-// get the next sibling. Panics if called on the root node
+// get the next sibling. Returns an error if called on the root node
 // returns nil of node has no next sibling
-func (n *TreeNode) NextSibling() *TreeNode {
+func (n *TreeNode) NextSibling() (*TreeNode, error) {
 	if n.IsRoot {
-		panic("root node cannot have siblings")
+		return nil, errRootSiblings
 	}
 	var ni int
 	for i, c := range n.Parent.Children {
@@ -175,16 +208,16 @@ func (n *TreeNode) NextSibling() *TreeNode {
 		}
 	}
 	if len(n.Parent.Children) < ni+1 {
-		return nil
+		return nil, nil
 	}
-	return n.Parent.Children[ni]
+	return n.Parent.Children[ni], nil
 }
 
-// get the previous sibling. Panics if called on the root node
+// get the previous sibling. Returns an error if called on the root node
 // returns nil of node has no previous sibling
-func (n *TreeNode) PreviousSibling() *TreeNode {
+func (n *TreeNode) PreviousSibling() (*TreeNode, error) {
 	if n.IsRoot {
-		panic("root node cannot have siblings")
+		return nil, errRootSiblings
 	}
 	var ni int
 	for i, c := range n.Parent.Children {
@@ -194,65 +227,143 @@ func (n *TreeNode) PreviousSibling() *TreeNode {
 		}
 	}
 	if ni < 0 {
-		return nil
+		return nil, nil
 	}
-	return n.Parent.Children[ni]
+	return n.Parent.Children[ni], nil
 }
 
-// traverse the tree starting from this node to all leafs, and sort
-// the children of each node
-func (n *TreeNode) SortDate(direction SortDirection) *TreeNode {
-	// first sort the children
-	n.Children.SortDate(direction)
-	// and repeat recursively
-	for _, c := range n.Children {
-		c.SortDate(direction)
-	}
-	return n
-}
-
-// return the creation date of the node. For leafs the date will be inferred
-// from the date-suffix of the source file i.e. 2022-03-05-myfile.md.
-// for non-leafs (folders) the date of the oldest children will be used.
+// return the creation date of the node. If the front matter mode is enabled,
+// and the front matter has a date, it is used. For leafs the date will be inferred
+// from the date-prefix of the source file i.e. 2022-03-05-myfile.md.
+// if the file has no date prefix, or its dir uses another naming scheme,
+// the sites date fallback is used.
+// for non-leafs (folders) with index, the date fallback of the index is used.
+// for other non-leafs the date of the oldest children will be used.
 // if the node is non-leaf and has no children, using oldest is not possible
 // in that case it will fallback to using the sourceFiles modtime.
-func (n *TreeNode) Date() time.Time {
-	if n.cache.date != nil {
-		return *n.cache.date
-	}
+func (n *TreeNode) Date() (time.Time, error) {
+	n.cache.dateOnce.Do(func() {
+		if n.useFront() && !n.front().Date.IsZero() {
+			n.cache.date = n.front().Date
+		} else if n.HasIndex() {
+			n.cache.date = n.fallbackDate()
+		} else if !n.IsLeaf {
+			if oldest := n.Children.Oldest(); oldest != nil {
+				n.cache.date, n.cache.dateErr = oldest.Date()
+			} else {
+				info, err := n.Entry.Info()
+				if err != nil {
+					n.cache.dateErr = fmt.Errorf("fileinfo: %w", err)
+					return
+				}
+				n.cache.date = info.ModTime()
+			}
+		} else if t, ok := parseDatePrefix(n.baseName()); ok && n.Naming() == NamingDate {
+			n.cache.date = t
+		} else {
+			n.cache.date = n.fallbackDate()
+		}
+	})
+	return n.cache.date, n.cache.dateErr
+}
 
-	var t time.Time
+// the date of the node, for ordering. Nodes whose date cannot be determined
+// are ordered as zero time, the error is reported when the tree is
+// fingerprinted
+func (n *TreeNode) date() time.Time {
+	t, _ := n.Date()
+	return t
+}
 
-	if !n.IsLeaf {
-		if oldest := n.Children.Oldest(); oldest != nil {
-			t = oldest.Date()
-		} else {
-			info, err := n.Entry.Info()
+// return the modification time of the source file. For non-leafs (folders)
+// the latest modification time of its children is used, or the modtime of
+// the dir itself, if it has no children
+func (n *TreeNode) LastModified() (time.Time, error) {
+	n.cache.modOnce.Do(func() {
+		for _, c := range n.Children {
+			t, err := c.LastModified()
 			if err != nil {
-				panic(fmt.Sprintf("fileinfo: %s", err.Error()))
+				n.cache.modErr = err
+				return
+			}
+			if t.After(n.cache.mod) {
+				n.cache.mod = t
 			}
-			t = info.ModTime()
 		}
-	} else {
-		var err error
-		ds := filepath.Base(n.SourcePath)[:10]
-		t, err = time.Parse("2006-01-02", ds)
+		if !n.cache.mod.IsZero() {
+			return
+		}
+		info, err := n.Entry.Info()
 		if err != nil {
-			panic(fmt.Sprintf("failed to parse date: %s", ds))
+			n.cache.modErr = fmt.Errorf("fileinfo: %w", err)
+			return
+		}
+		n.cache.mod = info.ModTime()
+	})
+	return n.cache.mod, n.cache.modErr
+}
+
+// traverse the tree starting from this node, and sort the children of each
+// dir by the sort order of its _dir.yaml. If it has none, the children of dirs
+// using weight prefixes are sorted by ascending weight, all others by
+// descending date
+func (n *TreeNode) SortDefault() *TreeNode {
+	key := n.config.Sort.By
+	if key == "" {
+		key = SortByDate
+		if n.config.Naming == NamingWeight {
+			key = SortByWeight
 		}
 	}
+	n.Children.sortBy(key, n.config.Sort.Direction)
+	for _, c := range n.Children {
+		c.SortDefault()
+	}
+	return n
+}
+
+// return the author of the node. This is the author of the front matter, if
+// the front matter mode is enabled. Otherwise the author set in the _dir.yaml
+// of its dir or the closest ancestor, and the site author otherwise
+func (n *TreeNode) Author() string {
+	if n.useFront() && n.front().Author != "" {
+		return n.front().Author
+	}
+	if n.config.Author != "" {
+		return n.config.Author
+	}
+	return n.Site.Author
+}
 
-	// cache the value for the next lookup
-	n.cache.date = &t
+// return the description set in the _dir.yaml, if the node is a dir
+func (n *TreeNode) Description() string {
+	return n.config.Description
+}
 
-	return t
+// return the params of the _dir.yaml files, merged from the root down to the
+// dir of the node, so that params of lower dirs override the ones above. If the
+// front matter mode is enabled, the params of the front matter are merged on
+// top
+func (n *TreeNode) Params() map[string]any {
+	if !n.useFront() || len(n.front().Params) == 0 {
+		return n.config.Params
+	}
+	params := make(map[string]any, len(n.config.Params)+len(n.front().Params))
+	for k, v := range n.config.Params {
+		params[k] = v
+	}
+	for k, v := range n.front().Params {
+		params[k] = v
+	}
+	return params
 }
 
-// return the author of the node
-// this is currently set to a static value
-// since we do not use front matter
-func (n *TreeNode) Author() string {
-	return POST_AUTHOR
+// return the tags of the front matter, if the front matter mode is enabled
+func (n *TreeNode) Tags() []string {
+	if !n.useFront() {
+		return nil
+	}
+	return n.front().Tags
 }
 
 // return true if node has children
@@ -262,26 +373,20 @@ func (n *TreeNode) HasChildren() bool {
 
 // return true if node has siblings
 func (n *TreeNode) HasSiblings() bool {
-	return !n.IsRoot && (len(n.Siblings()) > 0)
+	return !n.IsRoot && len(n.Parent.Children) > 1
 }
 
 type TreeNodeList []*TreeNode
 
-// sorts the child list in place. Making the sort permanent
+// return a copy of the list sorted by date. The list itself is left untouched,
+// since it is shared by all pages rendering concurrently
 func (tc TreeNodeList) SortDate(direction SortDirection) TreeNodeList {
-	sort.SliceStable(tc, func(i, j int) bool {
-		var a, b int
-		if direction == SortDirectionAscending {
-			a = i
-			b = j
-		} else {
-			a = j
-			b = i
-		}
-		return tc[a].Date().Before(tc[b].Date())
-	})
-	// returns itself for chaining
-	return tc
+	return tc.sorted(SortByDate, direction)
+}
+
+// return a copy of the list sorted by weight
+func (tc TreeNodeList) SortWeight(direction SortDirection) TreeNodeList {
+	return tc.sorted(SortByWeight, direction)
 }
 
 // get the oldest (Date) child. Since this is calling
@@ -295,7 +400,7 @@ func (tc TreeNodeList) Oldest() *TreeNode {
 	shadow := make(TreeNodeList, len(tc))
 	copy(shadow, tc)
 	sort.SliceStable(shadow, func(i, j int) bool {
-		return shadow[i].Date().Before(shadow[j].Date())
+		return shadow[i].date().Before(shadow[j].date())
 	})
 	return shadow[0]
 }
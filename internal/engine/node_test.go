@@ -0,0 +1,53 @@
+package engine
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestTitle(t *testing.T) {
+	root := testTree(t, fstest.MapFS{
+		"index.md":                     {Data: []byte("# Home\n")},
+		"emphasis.md":                  {Data: []byte("# Hello *World* `code`\n")},
+		"later.md":                     {Data: []byte("intro\n\n## Sub\n\n# Main\n")},
+		"setext.md":                    {Data: []byte("Setext\n======\n")},
+		"getting-started.md":           {Data: []byte("## Sub\n")},
+		"fenced.md":                    {Data: []byte("```\n# not a heading\n```\n")},
+		"k8s-api.md":                   {Data: []byte("hello\n")},
+		"2024-01-01-rest-api-guide.md": {Data: []byte("")},
+		"api-docs/page.md":             {Data: []byte("# Page\n")},
+		"guide/README.md":              {Data: []byte("# The Guide\n")},
+		"guide/_dir.yaml":              {Data: []byte("naming: weight\n")},
+		"guide/01-cli-usage.md":        {Data: []byte("")},
+		"titled/_dir.yaml":             {Data: []byte("title: From Config\n")},
+		"titled/index.md":              {Data: []byte("# From Index\n")},
+	}, WithAcronyms("API", "K8s", "CLI"))
+
+	tests := []struct {
+		node string
+		want string
+	}{
+		// the first level 1 heading, as plain text
+		{node: ".", want: "Home"},
+		{node: "emphasis.md", want: "Hello World code"},
+		{node: "later.md", want: "Main"},
+		{node: "setext.md", want: "Setext"},
+		// the index of a dir
+		{node: "guide", want: "The Guide"},
+		// the _dir.yaml before the index
+		{node: "titled", want: "From Config"},
+		// the name, title cased, with the acronyms as given
+		{node: "getting-started.md", want: "Getting Started"},
+		{node: "fenced.md", want: "Fenced"},
+		{node: "k8s-api.md", want: "K8s API"},
+		{node: "2024-01-01-rest-api-guide.md", want: "Rest API Guide"},
+		{node: "api-docs", want: "API Docs"},
+		{node: "guide/01-cli-usage.md", want: "CLI Usage"},
+	}
+
+	for _, tt := range tests {
+		if got := findNode(t, root, tt.node).Title(); got != tt.want {
+			t.Errorf("%s: title = %q, want %q", tt.node, got, tt.want)
+		}
+	}
+}
@@ -1,18 +1,74 @@
 package engine
 
+import "io/fs"
+
 type Options struct {
 	source      string
 	dist        string
 	theme       string
+	themeFS     fs.FS
 	chromaStyle string
+	clean       bool
+	jobs        int
+	minifyRes   bool
+	ignore      []string
+	feeds       FeedOptions
+	robots      string
+	site        *Site
 }
 
 type Option func(opts *Options)
 
+// set the base url of the site, i.e. https://example.com. Feeds are only
+// generated, if it is set
+func WithBaseURL(url string) Option {
+	return func(opts *Options) {
+		opts.site.BaseURL = url
+	}
+}
+
+// configure the feeds of the site
+func WithFeeds(feeds FeedOptions) Option {
+	return func(opts *Options) {
+		opts.feeds = feeds
+	}
+}
+
+// use the file as robots.txt, instead of the default one allowing everything.
+// The sitemap is appended to it, unless it references one already
+func WithRobots(path string) Option {
+	return func(opts *Options) {
+		opts.robots = path
+	}
+}
+
+// include drafts and scheduled posts in the site
+func WithDrafts(drafts bool) Option {
+	return func(opts *Options) {
+		opts.site.Drafts = drafts
+	}
+}
+
+// ignore the paths in the source dir matching the gitignore style patterns, in
+// addition to the ones of the .doktriignore
+func WithIgnore(patterns ...string) Option {
+	return func(opts *Options) {
+		opts.ignore = append(opts.ignore, patterns...)
+	}
+}
+
+// minify the resources in the docs dir, if their media type is supported by
+// the minifier, instead of copying them verbatim
+func WithMinifyResources(minify bool) Option {
+	return func(opts *Options) {
+		opts.minifyRes = minify
+	}
+}
+
 func WithAuthor(author string) Option {
 	return func(opts *Options) {
 		if author != "" {
-			POST_AUTHOR = author
+			opts.site.Author = author
 		}
 	}
 }
@@ -20,7 +76,7 @@ func WithAuthor(author string) Option {
 func WithContextPath(path string) Option {
 	return func(opts *Options) {
 		if path != "" {
-			CONTEXT_PATH = path
+			opts.site.ContextPath = path
 		}
 	}
 }
@@ -37,14 +93,63 @@ func WithDist(dist string) Option {
 	}
 }
 
+// use the theme at the given path, which may be a dir or an archive, or the
+// bundled theme with the given name
 func WithTheme(theme string) Option {
 	return func(opts *Options) {
 		opts.theme = theme
 	}
 }
 
+// use the given file system as theme. This takes precedence over WithTheme
+func WithThemeFS(fsys fs.FS) Option {
+	return func(opts *Options) {
+		opts.themeFS = fsys
+	}
+}
+
 func WithChromaStyle(style string) Option {
 	return func(opts *Options) {
 		opts.chromaStyle = style
 	}
 }
+
+// discard the build manifest and render everything from scratch
+func WithClean(clean bool) Option {
+	return func(opts *Options) {
+		opts.clean = clean
+	}
+}
+
+// the number of pages to render concurrently. Values below 1 use the number of
+// available cpus
+func WithJobs(jobs int) Option {
+	return func(opts *Options) {
+		opts.jobs = jobs
+	}
+}
+
+// the sources to take the date of undated posts from, tried in order
+func WithDateFallback(sources ...DateSource) Option {
+	return func(opts *Options) {
+		if len(sources) > 0 {
+			opts.site.DateFallback = sources
+		}
+	}
+}
+
+// let the front matter of the nodes override title, date, author, layout and
+// params
+func WithFrontMatter(enabled bool) Option {
+	return func(opts *Options) {
+		opts.site.FrontMatter = enabled
+	}
+}
+
+// set the acronyms to keep as is, when titles are derived from file names,
+// i.e. API or K8s
+func WithAcronyms(acronyms ...string) Option {
+	return func(opts *Options) {
+		opts.site.Acronyms = append(opts.site.Acronyms, acronyms...)
+	}
+}
@@ -0,0 +1,130 @@
+package engine
+
+import (
+	"io/fs"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// a resource is a non markdown file in the docs dir, such as an image next to
+// a post. It belongs to the node of its dir, and is copied next to the page of
+// that node, under its original name. Posts that link to it, additionally own
+// a copy of it, next to their own page
+type Resource struct {
+	// the name relative to the owner, i.e. diagram.png or img/diagram.png
+	Name string
+	// the path within the source fs
+	SourcePath string
+	// the node the resource belongs to
+	Owner *TreeNode
+}
+
+// report whether the file is a resource rather than a markdown document
+func isResource(name string) bool {
+	return path.Ext(name) != ".md"
+}
+
+// return the path of the resource as its used on the web page
+func (r *Resource) Path() string {
+	return r.Owner.Path() + r.Name
+}
+
+// return the media type by the file extension, i.e. image/png. It is empty,
+// if the extension is unknown
+func (r *Resource) MediaType() string {
+	mt, _, _ := mime.ParseMediaType(mime.TypeByExtension(path.Ext(r.Name)))
+	return mt
+}
+
+// return true if the resource is an image
+func (r *Resource) IsImage() bool {
+	return strings.HasPrefix(r.MediaType(), "image/")
+}
+
+// return the size of the file in bytes
+func (r *Resource) Size() (int64, error) {
+	info, err := fs.Stat(r.Owner.fs, r.SourcePath)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// the path of the output, relative to the dist dir
+func (r *Resource) outPath() string {
+	return r.Owner.slug() + r.Name
+}
+
+// return the resources of the node, such as images and other attachments. For
+// dirs, these are the files in the dir. For leafs, these are the files the
+// markdown links to
+func (n *TreeNode) Resources() []*Resource {
+	return n.resources
+}
+
+// give each leaf the resources its markdown links to by a relative path, such
+// as ![](diagram.png) or [slides](files/slides.pdf). Since a post is rendered
+// into a dir of its own, the links would miss the files of its dir otherwise.
+// The leaf gets its own copy of them, so that the links resolve the same way
+// on the page as they do in the docs dir. Links leaving the dir of the post are
+// left alone
+func attachResources(root *TreeNode) error {
+	bySource := make(map[string]*Resource)
+	var collect func(n *TreeNode)
+	collect = func(n *TreeNode) {
+		for _, r := range n.resources {
+			bySource[r.SourcePath] = r
+		}
+		for _, c := range n.Children {
+			collect(c)
+		}
+	}
+	collect(root)
+
+	var visit func(n *TreeNode) error
+	visit = func(n *TreeNode) error {
+		if !n.IsLeaf {
+			for _, c := range n.Children {
+				if err := visit(c); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		b, err := n.Content()
+		if err != nil {
+			return newRenderError(n, err)
+		}
+		seen := make(map[string]bool)
+		for _, link := range n.Site.document(b).Links() {
+			name, ok := relativeLink(link)
+			if !ok || seen[name] {
+				continue
+			}
+			r, ok := bySource[path.Join(path.Dir(n.SourcePath), name)]
+			if !ok {
+				continue
+			}
+			seen[name] = true
+			n.resources = append(n.resources, &Resource{Name: name, SourcePath: r.SourcePath, Owner: n})
+		}
+		return nil
+	}
+	return visit(root)
+}
+
+// return the cleaned path of the link, if it is relative and stays within the
+// dir it is relative to
+func relativeLink(link string) (string, bool) {
+	u, err := url.Parse(link)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" || path.IsAbs(u.Path) {
+		return "", false
+	}
+	name := path.Clean(u.Path)
+	if name == "." || name == ".." || strings.HasPrefix(name, "../") {
+		return "", false
+	}
+	return name, true
+}
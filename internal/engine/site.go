@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+)
+
+// the site holds the settings shared by all nodes of a tree. Each engine has
+// its own site, so that multiple engines can be used in the same process. The
+// site is reachable from every node, i.e. via .Site or .Root.Site in templates
+type Site struct {
+	// the context path can be used if the page is not hosted at the domain root
+	ContextPath string
+	// the scheme and host the site is served from, i.e. https://example.com.
+	// It is required for absolute urls, such as in the feeds
+	BaseURL string
+	// the post author is used for all posts
+	Author string
+	// the sources to take the date of undated posts from, tried in order
+	DateFallback []DateSource
+	// words that are spelled as given in titles derived from file names,
+	// instead of being title cased, i.e. API or K8s
+	Acronyms []string
+	// if true, the front matter of the nodes overrides the values derived
+	// from the file names and the _dir.yaml files
+	FrontMatter bool
+	// if true, drafts and scheduled posts are part of the site
+	Drafts bool
+	// the markdown engine and docs dir of the engine, so that nodes can
+	// inspect their sources
+	markdown goldmark.Markdown
+	docs     string
+	// the documents parsed during the current build
+	documents *documentCache
+	// the time the current build started
+	buildTime time.Time
+}
+
+func NewSite() *Site {
+	return &Site{
+		ContextPath:  "/",
+		Author:       "Anonymous",
+		DateFallback: []DateSource{DateSourceFrontMatter, DateSourceGit, DateSourceModTime},
+	}
+}
+
+// get the parsed document for the markdown source. During a build, documents
+// are taken from the cache of the build
+func (s *Site) document(source []byte) *document {
+	if s.documents == nil {
+		return parseDocument(s.markdown, source)
+	}
+	return s.documents.get(source)
+}
+
+// convert the name to a human readable title. dashes are replaced with spaces
+// and the word tokens are title cased. Words matching an acronym, regardless of
+// case, are replaced with the acronym
+func (s *Site) titleCase(name string) string {
+	caser := cases.Title(language.English)
+	words := strings.Fields(strings.ReplaceAll(name, "-", " "))
+	for i, w := range words {
+		words[i] = caser.String(w)
+		for _, a := range s.Acronyms {
+			if strings.EqualFold(w, a) {
+				words[i] = a
+				break
+			}
+		}
+	}
+	return strings.Join(words, " ")
+}
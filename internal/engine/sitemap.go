@@ -0,0 +1,175 @@
+package engine
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	sitemapName = "sitemap.xml"
+	robotsName  = "robots.txt"
+	// the max number of urls per sitemap, as defined by sitemaps.org. Larger
+	// sites get a sitemap index, referencing multiple sitemaps
+	sitemapMaxURLs = 50000
+	// the robots.txt, if the project has none
+	defaultRobots = "User-agent: *\nAllow: /\n"
+)
+
+// return true if the node should be kept out of the sitemap and search engines.
+// This is the case, if its front matter or the _dir.yaml of its dir, or any
+// dir above, has noindex: true
+func (n *TreeNode) NoIndex() bool {
+	return n.config.NoIndex || n.front().NoIndex
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+	// the time behind LastMod, to find the most recent one
+	modified time.Time
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []sitemapURL `xml:"sitemap"`
+}
+
+// write the sitemap of all nodes, except drafts and noindex nodes, and the
+// robots.txt referencing it. Like the feeds, they require the base url
+func (e Engine) writeSitemap(dist string, prev, next *Manifest, root *TreeNode) error {
+	if e.site.BaseURL == "" {
+		return nil
+	}
+
+	var urls []sitemapURL
+	var visit func(n *TreeNode) error
+	visit = func(n *TreeNode) error {
+		if n.IsDraft() {
+			return nil
+		}
+		if !n.NoIndex() {
+			lastmod, err := n.LastModified()
+			if err != nil {
+				if lastmod, err = n.Date(); err != nil {
+					return newRenderError(n, err)
+				}
+			}
+			urls = append(urls, sitemapURL{
+				Loc:      e.site.absURL(n.Path()),
+				LastMod:  lastmod.Format(time.RFC3339),
+				modified: lastmod,
+			})
+		}
+		for _, c := range n.Children {
+			if err := visit(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := visit(root); err != nil {
+		return err
+	}
+
+	outputs := splitSitemap(urls, sitemapMaxURLs, func(name string) string {
+		return e.site.absURL(path.Join(e.site.ContextPath, name))
+	})
+
+	for name, v := range outputs {
+		b, err := xml.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return fmt.Errorf("encode %s: %w", name, err)
+		}
+		if err := writeTracked(dist, prev, next, name, append([]byte(xml.Header), b...)); err != nil {
+			return err
+		}
+	}
+
+	robots, err := e.robots()
+	if err != nil {
+		return err
+	}
+	return writeTracked(dist, prev, next, robotsName, robots)
+}
+
+// return the sitemaps by their output name. Up to limit urls, that is only the
+// sitemap.xml. Otherwise, the urls are split into sitemap-1.xml, sitemap-2.xml,
+// ... and the sitemap.xml is an index referencing them by the url loc returns.
+// The lastmod of each is the most recent one of its urls, so that the index
+// only changes along with the pages
+func splitSitemap(urls []sitemapURL, limit int, loc func(name string) string) map[string]any {
+	outputs := make(map[string]any)
+	if len(urls) <= limit {
+		outputs[sitemapName] = sitemapURLSet{URLs: urls}
+		return outputs
+	}
+	index := sitemapIndex{}
+	for i := 0; i*limit < len(urls); i++ {
+		chunk := urls[i*limit : min((i+1)*limit, len(urls))]
+		name := fmt.Sprintf("sitemap-%d.xml", i+1)
+		outputs[name] = sitemapURLSet{URLs: chunk}
+		var latest sitemapURL
+		for _, u := range chunk {
+			if u.modified.After(latest.modified) {
+				latest = u
+			}
+		}
+		index.Sitemaps = append(index.Sitemaps, sitemapURL{Loc: loc(name), LastMod: latest.LastMod})
+	}
+	outputs[sitemapName] = index
+	return outputs
+}
+
+// the content of the robots.txt, with a reference to the sitemap. It is read
+// from the configured file or the robots.txt in the source dir, and falls back
+// to allowing everything
+func (e Engine) robots() ([]byte, error) {
+	p := e.robotsPath
+	if p == "" {
+		p = filepath.Join(e.src, robotsName)
+		if _, err := os.Stat(p); errors.Is(err, fs.ErrNotExist) {
+			p = ""
+		}
+	}
+	b := []byte(defaultRobots)
+	if p != "" {
+		var err error
+		if b, err = os.ReadFile(p); err != nil {
+			return nil, fmt.Errorf("read robots.txt: %w", err)
+		}
+	}
+	s := string(b)
+	if !strings.Contains(strings.ToLower(s), "sitemap:") {
+		if s != "" && !strings.HasSuffix(s, "\n") {
+			s += "\n"
+		}
+		s += "\nSitemap: " + e.site.absURL(path.Join(e.site.ContextPath, sitemapName)) + "\n"
+	}
+	return []byte(s), nil
+}
+
+// write the generated output, unless it is fresh, and record it in the next
+// manifest. An error is returned, if another output has the same path
+func writeTracked(dist string, prev, next *Manifest, out string, b []byte) error {
+	if _, ok := next.Outputs[out]; ok {
+		return fmt.Errorf("%s conflicts with another output", out)
+	}
+	fp := newFingerprint().AddString(out).Add(b).String()
+	next.Outputs[out] = fp
+	if prev.Fresh(dist, out, fp) {
+		return nil
+	}
+	return writeOutput(filepath.Join(dist, filepath.FromSlash(out)), b)
+}
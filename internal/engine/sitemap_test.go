@@ -0,0 +1,88 @@
+package engine
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSitemapURLs(n int) []sitemapURL {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	urls := make([]sitemapURL, n)
+	for i := range urls {
+		// the most recent url is in the middle of the list, not at its end
+		mod := base.Add(time.Duration(i%1000) * time.Hour)
+		urls[i] = sitemapURL{
+			Loc:      fmt.Sprintf("https://example.com/post-%d/", i),
+			LastMod:  mod.Format(time.RFC3339),
+			modified: mod,
+		}
+	}
+	return urls
+}
+
+func testSitemapLoc(name string) string {
+	return "https://example.com/" + name
+}
+
+func TestSplitSitemap(t *testing.T) {
+	// up to the limit, there is a single sitemap
+	outputs := splitSitemap(testSitemapURLs(sitemapMaxURLs), sitemapMaxURLs, testSitemapLoc)
+	if len(outputs) != 1 {
+		t.Fatalf("got %d sitemaps, want 1", len(outputs))
+	}
+	set, ok := outputs[sitemapName].(sitemapURLSet)
+	if !ok || len(set.URLs) != sitemapMaxURLs {
+		t.Fatalf("sitemap.xml is no url set of %d urls", sitemapMaxURLs)
+	}
+
+	// above the limit, the urls are split and the sitemap.xml is an index
+	urls := testSitemapURLs(sitemapMaxURLs + 1)
+	outputs = splitSitemap(urls, sitemapMaxURLs, testSitemapLoc)
+	if len(outputs) != 3 {
+		t.Fatalf("got %d sitemaps, want 3", len(outputs))
+	}
+	for name, want := range map[string]int{"sitemap-1.xml": sitemapMaxURLs, "sitemap-2.xml": 1} {
+		set, ok := outputs[name].(sitemapURLSet)
+		if !ok || len(set.URLs) != want {
+			t.Errorf("%s: want a url set of %d urls", name, want)
+		}
+	}
+	if got := outputs["sitemap-2.xml"].(sitemapURLSet).URLs[0].Loc; got != urls[sitemapMaxURLs].Loc {
+		t.Errorf("sitemap-2.xml starts with %s, want %s", got, urls[sitemapMaxURLs].Loc)
+	}
+
+	index, ok := outputs[sitemapName].(sitemapIndex)
+	if !ok {
+		t.Fatal("sitemap.xml is no index")
+	}
+	want := []sitemapURL{
+		// the most recent of the first chunk is 999 hours after the base
+		{Loc: "https://example.com/sitemap-1.xml", LastMod: "2024-02-11T15:00:00Z"},
+		{Loc: "https://example.com/sitemap-2.xml", LastMod: urls[sitemapMaxURLs].LastMod},
+	}
+	if len(index.Sitemaps) != len(want) {
+		t.Fatalf("index has %d sitemaps, want %d", len(index.Sitemaps), len(want))
+	}
+	for i, w := range want {
+		if got := index.Sitemaps[i]; got.Loc != w.Loc || got.LastMod != w.LastMod {
+			t.Errorf("index entry %d = %s %s, want %s %s", i, got.Loc, got.LastMod, w.Loc, w.LastMod)
+		}
+	}
+
+	b, err := xml.Marshal(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><sitemap><loc>`) {
+		t.Errorf("unexpected index encoding: %.200s", b)
+	}
+
+	// the index stays the same, as long as the urls do
+	again := splitSitemap(testSitemapURLs(sitemapMaxURLs+1), sitemapMaxURLs, testSitemapLoc)
+	if fmt.Sprint(again[sitemapName]) != fmt.Sprint(index) {
+		t.Error("the index changed, without any change to the urls")
+	}
+}
@@ -0,0 +1,122 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// a key to sort nodes by
+type SortKey string
+
+const (
+	// sort by the title, ignoring case
+	SortByTitle SortKey = "title"
+	// sort by the normalized name
+	SortByName SortKey = "name"
+	// sort by the weight prefix, for manual ordering
+	SortByWeight SortKey = "weight"
+	// sort by the date of the node
+	SortByDate SortKey = "date"
+	// sort by the modification time of the source file
+	SortByLastModified SortKey = "last-modified"
+)
+
+// all sort keys, in the order they are listed in error messages
+var sortKeys = []SortKey{SortByTitle, SortByName, SortByWeight, SortByDate, SortByLastModified}
+
+// parse the sort key, i.e. from a _dir.yaml
+func ParseSortKey(s string) (SortKey, error) {
+	for _, k := range sortKeys {
+		if SortKey(s) == k {
+			return k, nil
+		}
+	}
+	names := make([]string, len(sortKeys))
+	for i, k := range sortKeys {
+		names[i] = string(k)
+	}
+	return "", fmt.Errorf("unknown sort key %q, must be one of %s", s, strings.Join(names, ", "))
+}
+
+// parse the sort direction, i.e. from a _dir.yaml
+func ParseSortDirection(s string) (SortDirection, error) {
+	switch d := SortDirection(s); d {
+	case SortDirectionAscending, SortDirectionDescending:
+		return d, nil
+	}
+	return "", fmt.Errorf("unknown sort direction %q, must be one of %s, %s", s, SortDirectionAscending, SortDirectionDescending)
+}
+
+// the default direction for the key. Dates are sorted newest first, all
+// other keys ascending
+func (k SortKey) defaultDirection() SortDirection {
+	if k == SortByDate || k == SortByLastModified {
+		return SortDirectionDescending
+	}
+	return SortDirectionAscending
+}
+
+// report whether a sorts before b, in ascending order
+func (k SortKey) less(a, b *TreeNode) bool {
+	switch k {
+	case SortByTitle:
+		return strings.ToLower(a.Title()) < strings.ToLower(b.Title())
+	case SortByName:
+		return a.Name() < b.Name()
+	case SortByWeight:
+		return a.Weight() < b.Weight()
+	case SortByLastModified:
+		at, _ := a.LastModified()
+		bt, _ := b.LastModified()
+		return at.Before(bt)
+	default:
+		return a.date().Before(b.date())
+	}
+}
+
+// sort the list in place by the given key and direction. If the direction is
+// empty, the default direction of the key is used. Nodes that are equal by
+// the key keep their order
+func (tc TreeNodeList) sortBy(key SortKey, direction SortDirection) TreeNodeList {
+	if direction == "" {
+		direction = key.defaultDirection()
+	}
+	sort.SliceStable(tc, func(i, j int) bool {
+		if direction == SortDirectionAscending {
+			return key.less(tc[i], tc[j])
+		}
+		return key.less(tc[j], tc[i])
+	})
+	// returns itself for chaining
+	return tc
+}
+
+// return a sorted copy of the list, leaving the list itself untouched.
+// The key is one of title, name, weight, date or last-modified. The
+// direction is asc or desc, and can be omitted to use the default of the key,
+// which is newest first for date and last-modified, and ascending otherwise.
+// This is meant for templates, i.e. {{ range .Children.SortBy "title" "asc" }}
+func (tc TreeNodeList) SortBy(key string, direction ...string) (TreeNodeList, error) {
+	k, err := ParseSortKey(key)
+	if err != nil {
+		return nil, err
+	}
+	var d SortDirection
+	if len(direction) > 1 {
+		return nil, fmt.Errorf("sort by %s: too many arguments", key)
+	}
+	if len(direction) == 1 && direction[0] != "" {
+		if d, err = ParseSortDirection(direction[0]); err != nil {
+			return nil, err
+		}
+	}
+	return tc.sorted(k, d), nil
+}
+
+// return a sorted copy of the list
+func (tc TreeNodeList) sorted(key SortKey, direction SortDirection) TreeNodeList {
+	shadow := make(TreeNodeList, len(tc))
+	copy(shadow, tc)
+	return shadow.sortBy(key, direction)
+}
@@ -0,0 +1,64 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSortBy(t *testing.T) {
+	root := testTree(t, fstest.MapFS{
+		"2024-01-01-alpha.md": {Data: []byte("# alpha\n")},
+		"2024-01-03-beta.md":  {Data: []byte("# Beta\n")},
+		"2024-01-02-gamma.md": {Data: []byte("# Gamma\n")},
+	})
+
+	names := func(l TreeNodeList) []string {
+		var s []string
+		for _, n := range l {
+			s = append(s, n.Name())
+		}
+		return s
+	}
+	// the default order of the dir, newest first
+	original := []string{"beta", "gamma", "alpha"}
+
+	tests := []struct {
+		args    []string
+		want    []string
+		wantErr bool
+	}{
+		{args: []string{"title"}, want: []string{"alpha", "beta", "gamma"}},
+		{args: []string{"title", "desc"}, want: []string{"gamma", "beta", "alpha"}},
+		{args: []string{"name", "asc"}, want: []string{"alpha", "beta", "gamma"}},
+		{args: []string{"date"}, want: []string{"beta", "gamma", "alpha"}},
+		{args: []string{"date", "asc"}, want: []string{"alpha", "gamma", "beta"}},
+		{args: []string{"date", ""}, want: []string{"beta", "gamma", "alpha"}},
+		// without weights, the order is kept
+		{args: []string{"weight"}, want: original},
+		{args: []string{"size"}, wantErr: true},
+		{args: []string{"title", "up"}, wantErr: true},
+		{args: []string{"title", "asc", "desc"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := root.Children.SortBy(tt.args[0], tt.args[1:]...)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("SortBy %v: expected an error", tt.args)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("SortBy %v: %v", tt.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(names(got), tt.want) {
+			t.Errorf("SortBy %v = %v, want %v", tt.args, names(got), tt.want)
+		}
+		// the children are shared by all pages, so they must stay as they are
+		if !reflect.DeepEqual(names(root.Children), original) {
+			t.Fatalf("SortBy %v changed the children to %v", tt.args, names(root.Children))
+		}
+	}
+}
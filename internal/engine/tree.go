@@ -3,49 +3,90 @@ package engine
 import (
 	"fmt"
 	"io/fs"
+	"path"
+	"strings"
 )
 
-func buildTree(srcFS fs.FS) (*TreeNode, error) {
-	var (
-		treeRoot      *TreeNode
-		currentBranch *TreeNode
-	)
+func buildTree(srcFS fs.FS, site *Site) (*TreeNode, error) {
+	var treeRoot *TreeNode
+	// the dir nodes by their path, to look up the parent of each entry
+	dirs := make(map[string]*TreeNode)
 
-	err := fs.WalkDir(srcFS, ".", func(path string, d fs.DirEntry, err error) error {
+	// dirs without markdown, such as img, are no nodes. Their files are
+	// resources of the closest dir above, that is a node
+	withMarkdown, err := markdownDirs(srcFS)
+	if err != nil {
+		return nil, fmt.Errorf("walk: %w", err)
+	}
+	owners := make(map[string]*TreeNode)
+
+	err = fs.WalkDir(srcFS, ".", func(p string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
-		// TODO: make sense of this scenario
-		// skip any index files, since they are meant
-		// to be read as dir content, later
-		if d.Name() == "index.md" {
+		// dir configs are read along with their dir
+		if d.Name() == DirConfigName {
+			return nil
+		}
+
+		parent := dirs[path.Dir(p)]
+
+		if d.IsDir() && p != "." && !withMarkdown[p] {
+			if parent != nil {
+				owners[p] = parent
+			} else {
+				owners[p] = owners[path.Dir(p)]
+			}
+			return nil
+		}
+		if parent == nil && p != "." {
+			owner := owners[path.Dir(p)]
+			owner.resources = append(owner.resources, &Resource{
+				Name:       relPath(owner.SourcePath, p),
+				SourcePath: p,
+				Owner:      owner,
+			})
+			return nil
+		}
+
+		// skip the index file of the dir, since it is the content of the
+		// dir node
+		if parent != nil && !d.IsDir() && p == parent.indexPath {
+			return nil
+		}
+
+		// non markdown files are resources of their dir, rather than nodes
+		if !d.IsDir() && isResource(p) {
+			parent.resources = append(parent.resources, &Resource{
+				Name:       d.Name(),
+				SourcePath: p,
+				Owner:      parent,
+			})
 			return nil
 		}
 
 		node := &TreeNode{
 			fs:         srcFS,
-			SourcePath: path,
+			SourcePath: p,
 			Entry:      d,
+			Site:       site,
 			cache:      &nodeCache{},
 			IsLeaf:     !d.IsDir(),
 		}
 
-		if path == "." {
+		if p == "." {
 			// if its ., set the root
 			node.IsRoot = true
 			treeRoot = node
-		} else if path == d.Name() {
-			// if path is equal to the name, start a new branch under root
-			node.Parent = treeRoot
-			currentBranch = node
-		} else if d.IsDir() {
-			// if its a dir, start a new branch under the current branch
-			node.Parent = currentBranch
-			currentBranch = node
 		} else {
-			// if its a file, add the parent without branching (leaf node)
-			node.Parent = currentBranch
+			// otherwise add it to the node of its dir
+			node.Parent = parent
+		}
+
+		if d.IsDir() {
+			dirs[p] = node
+			node.indexPath = findIndex(srcFS, p)
 		}
 
 		// if its not the root, add the root to the node
@@ -54,6 +95,20 @@ func buildTree(srcFS fs.FS) (*TreeNode, error) {
 			node.Parent.Children = append(node.Parent.Children, node)
 		}
 
+		// cascade the dir config of the parent, and apply the own one on
+		// top, if its a dir. Leafs take the config of their dir as is
+		var parentConfig, own DirConfig
+		if !node.IsRoot {
+			parentConfig = node.Parent.config
+		}
+		if d.IsDir() {
+			own, err = readDirConfig(srcFS, p)
+			if err != nil {
+				return err
+			}
+		}
+		node.config = parentConfig.cascade(own)
+
 		// always point to the tree root, even for the root itself
 		node.Root = treeRoot
 
@@ -67,3 +122,53 @@ func buildTree(srcFS fs.FS) (*TreeNode, error) {
 
 	return treeRoot, nil
 }
+
+// the set of dirs, that contain markdown files, directly or further down
+func markdownDirs(srcFS fs.FS) (map[string]bool, error) {
+	dirs := make(map[string]bool)
+	err := fs.WalkDir(srcFS, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || isResource(p) {
+			return nil
+		}
+		for dir := path.Dir(p); !dirs[dir]; dir = path.Dir(dir) {
+			dirs[dir] = true
+			if dir == "." {
+				break
+			}
+		}
+		return nil
+	})
+	return dirs, err
+}
+
+// the slash separated path p, relative to the dir
+func relPath(dir, p string) string {
+	if dir == "." {
+		return p
+	}
+	return strings.TrimPrefix(p, dir+"/")
+}
+
+// return an error, if two nodes render to the same path, such as notes.md and
+// its draft _notes.md, or 2024-01-01-notes.md and notes.md
+func checkPaths(root *TreeNode) error {
+	seen := make(map[string]*TreeNode)
+	var visit func(n *TreeNode) error
+	visit = func(n *TreeNode) error {
+		p := n.Path()
+		if other, ok := seen[p]; ok {
+			return newRenderError(n, fmt.Errorf("conflicts with %s, both render to %s", other.SourcePath, p))
+		}
+		seen[p] = n
+		for _, c := range n.Children {
+			if err := visit(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return visit(root)
+}
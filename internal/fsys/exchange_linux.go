@@ -0,0 +1,18 @@
+package fsys
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// atomically swap the paths a and b. Both must exist and be on the same file
+// system. errExchangeUnsupported is returned, if the kernel or the file system
+// cannot do it
+func exchange(a, b string) error {
+	err := unix.Renameat2(unix.AT_FDCWD, a, unix.AT_FDCWD, b, unix.RENAME_EXCHANGE)
+	if errors.Is(err, unix.ENOSYS) || errors.Is(err, unix.EINVAL) || errors.Is(err, unix.ENOTSUP) {
+		return errExchangeUnsupported
+	}
+	return err
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package fsys
+
+// swapping two paths atomically is only supported on linux
+func exchange(a, b string) error {
+	return errExchangeUnsupported
+}
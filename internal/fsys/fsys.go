@@ -2,9 +2,11 @@ package fsys
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"io/fs"
 	"os"
+	"path/filepath"
 )
 
 func IsEmptyDir(name string) (bool, error) {
@@ -30,3 +32,113 @@ func PathExists(path string) (bool, error) {
 	}
 	return false, err
 }
+
+// recreate the given files of src at dst, hard linking them instead of copying
+// them. The names are slash separated and relative to src. Files that don't
+// exist in src are skipped. If a file cannot be linked, i.e. because dst is on
+// another device, it is copied instead. Callers must not write into linked
+// files in place, but remove them first, since the content is shared with src
+func LinkFiles(src, dst string, names []string) error {
+	for _, name := range names {
+		from := filepath.Join(src, filepath.FromSlash(name))
+		info, err := os.Stat(from)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		to := filepath.Join(dst, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+			return err
+		}
+		if err := os.Link(from, to); err == nil {
+			continue
+		}
+		if err := CopyFile(from, to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copy the file at src to dst, truncating dst if it exists
+func CopyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// create the named file. Unlike os.Create, an existing file is removed first
+// instead of being truncated, so that hard links to it keep their content
+func CreateFresh(name string) (*os.File, error) {
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return os.Create(name)
+}
+
+var errExchangeUnsupported = errors.New("exchange not supported")
+
+// replace the directory dst with src by renaming. On linux, both are swapped in
+// a single atomic step, so that dst always exists. Elsewhere, or if the file
+// system does not support it, dst is moved aside first and only removed once
+// src is in place. In that case, dst is briefly missing. If src cannot be
+// moved, the previous dst is restored. Once src is in place, the replacement
+// has succeeded, so failing to remove the previous dst is not an error
+func ReplaceDir(src, dst string) error {
+	exists, err := PathExists(dst)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		err := exchange(src, dst)
+		if err == nil {
+			// src holds the previous dst now
+			_ = os.RemoveAll(src)
+			return nil
+		}
+		if !errors.Is(err, errExchangeUnsupported) {
+			return err
+		}
+	}
+
+	old := dst + ".old"
+	if err := os.RemoveAll(old); err != nil {
+		return err
+	}
+
+	if exists {
+		if err := os.Rename(dst, old); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(src, dst); err != nil {
+		if exists {
+			if rerr := os.Rename(old, dst); rerr != nil {
+				return fmt.Errorf("%w: restore %s: %v", err, dst, rerr)
+			}
+		}
+		return err
+	}
+
+	// a leftover is removed before the next replacement
+	_ = os.RemoveAll(old)
+	return nil
+}
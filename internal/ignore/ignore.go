@@ -0,0 +1,246 @@
+// Package ignore matches paths against gitignore style patterns
+package ignore
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// the name of the ignore file, relative to the source dir
+const FileName = ".doktriignore"
+
+// the patterns that are always applied before the ones of the ignore file and
+// the config. They can be negated, i.e. with !.well-known
+var Defaults = []string{".*", "*~", "*.swp", "*.swx", `\#*#`}
+
+type rule struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// the matcher holds a list of rules, which are applied in order, so that later
+// rules override earlier ones
+type Matcher struct {
+	rules []rule
+}
+
+// create a matcher from the given patterns. Blank lines and lines starting
+// with # are skipped
+func New(patterns ...string) *Matcher {
+	m := &Matcher{}
+	m.Add(patterns...)
+	return m
+}
+
+// load the default patterns, the patterns of the ignore file in the given dir,
+// if it exists, and the extra patterns, in that order
+func Load(dir string, extra ...string) (*Matcher, error) {
+	m := New(Defaults...)
+	f, err := os.Open(filepath.Join(dir, FileName))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		defer f.Close()
+		if err := m.Read(f); err != nil {
+			return nil, err
+		}
+	}
+	m.Add(extra...)
+	return m, nil
+}
+
+// add the patterns read line by line from the reader
+func (m *Matcher) Read(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		m.Add(s.Text())
+	}
+	return s.Err()
+}
+
+// add the patterns. Blank lines and lines starting with # are skipped
+func (m *Matcher) Add(patterns ...string) {
+	for _, p := range patterns {
+		if r, ok := parse(p); ok {
+			m.rules = append(m.rules, r)
+		}
+	}
+}
+
+// report whether the slash separated path, relative to the dir of the ignore
+// file, is ignored. A path is also ignored, if any of its parent dirs is
+func (m *Matcher) Match(name string, isDir bool) bool {
+	if m == nil || len(m.rules) == 0 {
+		return false
+	}
+	name = strings.Trim(path.Clean(name), "/")
+	if name == "." || name == "" {
+		return false
+	}
+	// a path cannot be included again, if a parent dir is excluded
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		if m.match(dir, true) {
+			return true
+		}
+	}
+	return m.match(name, isDir)
+}
+
+// match the path only, ignoring its parents
+func (m *Matcher) match(name string, isDir bool) bool {
+	ignored := false
+	for _, r := range m.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if r.re.MatchString(name) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// convert the gitignore pattern to a rule
+func parse(p string) (rule, bool) {
+	p = strings.TrimRight(p, " \t\r")
+	if p == "" || strings.HasPrefix(p, "#") {
+		return rule{}, false
+	}
+
+	var r rule
+	if strings.HasPrefix(p, "!") {
+		r.negate = true
+		p = p[1:]
+	} else if strings.HasPrefix(p, `\`) {
+		// allow to escape a leading ! or #
+		p = p[1:]
+	}
+
+	if strings.HasSuffix(p, "/") {
+		r.dirOnly = true
+		p = strings.TrimRight(p, "/")
+	}
+	if p == "" {
+		return rule{}, false
+	}
+
+	// a pattern with a slash in the beginning or middle is relative to the
+	// dir of the ignore file, otherwise it matches at any level
+	anchored := strings.Contains(p, "/")
+	p = strings.TrimPrefix(p, "/")
+
+	var b strings.Builder
+	b.WriteString("^")
+	if !anchored {
+		b.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case strings.HasPrefix(p[i:], "**/"):
+			// zero or more dirs
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(p[i:], "/**") && i+3 == len(p):
+			// everything inside
+			b.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(p[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			j := strings.IndexByte(p[i+1:], ']')
+			if j < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := p[i+1 : i+1+j]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += j + 1
+		case c == '\\' && i+1 < len(p):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(p[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return rule{}, false
+	}
+	r.re = re
+	return r, true
+}
+
+// the filtered fs hides the ignored files and dirs of the underlying fs, as if
+// they did not exist. The prefix is the path of the fs relative to the dir of
+// the ignore file, i.e. docs
+type filteredFS struct {
+	fsys   fs.FS
+	m      *Matcher
+	prefix string
+}
+
+// hide the files and dirs of the fs, that are ignored by the matcher
+func FS(fsys fs.FS, m *Matcher, prefix string) fs.FS {
+	return filteredFS{fsys, m, prefix}
+}
+
+func (f filteredFS) ignored(name string, isDir bool) bool {
+	return name != "." && f.m.Match(path.Join(f.prefix, name), isDir)
+}
+
+func (f filteredFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	file, err := f.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if f.ignored(name, info.IsDir()) {
+		file.Close()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return file, nil
+}
+
+func (f filteredFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if f.ignored(name, true) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	entries, err := fs.ReadDir(f.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	out := entries[:0]
+	for _, e := range entries {
+		if !f.ignored(path.Join(name, e.Name()), e.IsDir()) {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
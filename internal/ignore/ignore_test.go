@@ -0,0 +1,153 @@
+package ignore
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		name     string
+		isDir    bool
+		want     bool
+	}{
+		// plain names match at any level
+		{[]string{"*.psd"}, "a.psd", false, true},
+		{[]string{"*.psd"}, "docs/blog/a.psd", false, true},
+		{[]string{"*.psd"}, "docs/a.png", false, false},
+		{[]string{"scratch"}, "docs/scratch", true, true},
+		{[]string{"scratch"}, "docs/scratch/notes.md", false, true},
+		{[]string{"scratch"}, "docs/scratchpad", true, false},
+
+		// a slash in the beginning or middle anchors the pattern
+		{[]string{"/docs/a.md"}, "docs/a.md", false, true},
+		{[]string{"/a.md"}, "docs/a.md", false, false},
+		{[]string{"docs/a.md"}, "docs/a.md", false, true},
+		{[]string{"docs/a.md"}, "x/docs/a.md", false, false},
+		{[]string{"docs/*.md"}, "docs/a.md", false, true},
+		{[]string{"docs/*.md"}, "docs/blog/a.md", false, false},
+
+		// trailing slash matches dirs only
+		{[]string{"build/"}, "build", true, true},
+		{[]string{"build/"}, "build", false, false},
+		{[]string{"build/"}, "build/out.md", false, true},
+
+		// double stars
+		{[]string{"docs/**/scratch/"}, "docs/scratch", true, true},
+		{[]string{"docs/**/scratch/"}, "docs/a/b/scratch", true, true},
+		{[]string{"docs/**/scratch/"}, "docs/a/b/scratch/c.md", false, true},
+		{[]string{"**/tmp"}, "tmp", true, true},
+		{[]string{"**/tmp"}, "a/b/tmp", false, true},
+		{[]string{"docs/**"}, "docs/a/b.md", false, true},
+		{[]string{"docs/**"}, "docs", true, false},
+		{[]string{"a/**/b"}, "a/b", false, true},
+		{[]string{"a/**/b"}, "a/x/y/b", false, true},
+		{[]string{"a/**/b"}, "a/xb", false, false},
+
+		// single star and question mark don't cross slashes
+		{[]string{"docs/*"}, "docs/a/b.md", false, true},
+		{[]string{"/*.md"}, "a.md", false, true},
+		{[]string{"/*.md"}, "docs/a.md", false, false},
+		{[]string{"?.md"}, "a.md", false, true},
+		{[]string{"?.md"}, "ab.md", false, false},
+
+		// character classes
+		{[]string{"[ab].md"}, "a.md", false, true},
+		{[]string{"[ab].md"}, "c.md", false, false},
+		{[]string{"[!ab].md"}, "c.md", false, true},
+		{[]string{"[!ab].md"}, "a.md", false, false},
+		{[]string{"[a-c].md"}, "b.md", false, true},
+
+		// escapes and regexp meta characters are literal
+		{[]string{`\#notes.md`}, "#notes.md", false, true},
+		{[]string{`\!important.md`}, "!important.md", false, true},
+		{[]string{`a\*.md`}, "a*.md", false, true},
+		{[]string{`a\*.md`}, "ab.md", false, false},
+		{[]string{"a+b.md"}, "a+b.md", false, true},
+		{[]string{"a.md"}, "a_md", false, false},
+		{[]string{"(x)"}, "(x)", false, true},
+
+		// comments and blank lines
+		{[]string{"# a.md"}, "# a.md", false, false},
+		{[]string{"", "   "}, "a.md", false, false},
+
+		// negation, the last matching rule wins
+		{[]string{"*.md", "!keep.md"}, "keep.md", false, false},
+		{[]string{"*.md", "!keep.md"}, "drop.md", false, true},
+		{[]string{"!keep.md", "*.md"}, "keep.md", false, true},
+		{[]string{"*.md", "!docs/*.md"}, "docs/a.md", false, false},
+
+		// a file cannot be included again, if its parent dir is excluded
+		{[]string{"docs/", "!docs/a.md"}, "docs/a.md", false, true},
+
+		// defaults
+		{Defaults, ".DS_Store", false, true},
+		{Defaults, "docs/.git", true, true},
+		{Defaults, "docs/.git/config", false, true},
+		{Defaults, "docs/notes.md~", false, true},
+		{Defaults, "docs/.notes.md.swp", false, true},
+		{Defaults, "docs/#notes.md#", false, true},
+		{Defaults, "docs/notes.md", false, false},
+		{append(Defaults, "!.well-known/"), "assets/.well-known", true, false},
+		{append(Defaults, "!.well-known/"), "assets/.well-known/security.txt", false, false},
+
+		// the root itself is never ignored
+		{[]string{"*"}, ".", true, false},
+		{[]string{"*"}, "", true, false},
+	}
+
+	for _, tt := range tests {
+		m := New(tt.patterns...)
+		if got := m.Match(tt.name, tt.isDir); got != tt.want {
+			t.Errorf("%q.Match(%q, dir=%v) = %v, want %v", tt.patterns, tt.name, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestNilMatcher(t *testing.T) {
+	var m *Matcher
+	if m.Match("a.md", false) {
+		t.Error("nil matcher must not ignore anything")
+	}
+}
+
+func TestFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.md":            {},
+		"a.psd":               {},
+		".DS_Store":           {},
+		"blog/post.md":        {},
+		"blog/scratch/wip.md": {},
+	}
+	m := New(append(Defaults, "*.psd", "docs/**/scratch/")...)
+
+	var got []string
+	err := fs.WalkDir(FS(fsys, m, "docs"), ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		got = append(got, p)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{".", "blog", "blog/post.md", "index.md"}
+	if len(got) != len(want) {
+		t.Fatalf("walk = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("walk = %q, want %q", got, want)
+		}
+	}
+
+	for _, name := range []string{"a.psd", ".DS_Store", "blog/scratch/wip.md"} {
+		if _, err := fs.Stat(FS(fsys, m, "docs"), name); err == nil {
+			t.Errorf("%s: expected to be hidden", name)
+		}
+	}
+}
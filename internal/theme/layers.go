@@ -0,0 +1,112 @@
+package theme
+
+import (
+	"errors"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// the layered fs stacks multiple file systems on top of each other. A file is
+// looked up in each layer in order, and the first layer that has it wins.
+// Reading a dir merges the entries of all layers
+type layeredFS []fs.FS
+
+// stack the given file systems, the first one being the top most layer
+func Layers(layers ...fs.FS) fs.FS {
+	if len(layers) == 1 {
+		return layers[0]
+	}
+	return layeredFS(layers)
+}
+
+func (l layeredFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	for _, layer := range l {
+		f, err := layer.Open(name)
+		if err == nil {
+			return f, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+func (l layeredFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	seen := make(map[string]fs.DirEntry)
+	found := false
+	for _, layer := range l {
+		entries, err := fs.ReadDir(layer, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		found = true
+		for _, e := range entries {
+			if _, ok := seen[e.Name()]; !ok {
+				seen[e.Name()] = e
+			}
+		}
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	entries := make([]fs.DirEntry, 0, len(seen))
+	for _, e := range seen {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// the subtree fs exposes only the given top level dir of the underlying fs,
+// under the same name. This allows to use a project dir as layer on top of a
+// theme, without exposing anything but i.e. the templates dir
+type subtreeFS struct {
+	fsys fs.FS
+	dir  string
+}
+
+// expose only the given top level dir of the fs
+func Subtree(fsys fs.FS, dir string) fs.FS {
+	return subtreeFS{fsys, dir}
+}
+
+func (s subtreeFS) contains(name string) bool {
+	return name == s.dir || strings.HasPrefix(name, s.dir+"/")
+}
+
+func (s subtreeFS) Open(name string) (fs.File, error) {
+	if name == "." {
+		return s.fsys.Open(name)
+	}
+	if !s.contains(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return s.fsys.Open(name)
+}
+
+func (s subtreeFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name == "." {
+		entries, err := fs.ReadDir(s.fsys, ".")
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.Name() == s.dir {
+				return []fs.DirEntry{e}, nil
+			}
+		}
+		return []fs.DirEntry{}, nil
+	}
+	if !s.contains(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	return fs.ReadDir(s.fsys, name)
+}
@@ -0,0 +1,282 @@
+package theme
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+//go:embed all:default
+var builtin embed.FS
+
+// the name of the theme that is used when none is given
+const DefaultName = "default"
+
+// the names of the themes bundled with the binary
+func Names() []string {
+	entries, err := fs.ReadDir(builtin, ".")
+	if err != nil {
+		panic(err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// get the bundled theme with the given name
+func Builtin(name string) (fs.FS, error) {
+	for _, n := range Names() {
+		if n == name {
+			return fs.Sub(builtin, name)
+		}
+	}
+	return nil, fmt.Errorf("unknown theme %q, available: %s", name, strings.Join(Names(), ", "))
+}
+
+// the name of the optional theme config, at the root of a theme
+const ConfigName = "theme.yaml"
+
+// the content of the theme.yaml
+type Config struct {
+	// the parent theme, given as path or name of a bundled theme. Relative
+	// paths are resolved against the location of the extending theme
+	Extends string `json:"extends,omitempty"`
+}
+
+// themes can extend each other, but not endlessly
+const maxExtends = 16
+
+// load the theme by path or name, and follow its extends chain. The returned
+// fs stacks the theme on top of its ancestors, so that files of the theme
+// override files of the same name in its parent. The local dirs of all themes
+// in the chain are returned as well, i.e. so they can be watched for changes
+func Load(pathOrName string) (fs.FS, []string, error) {
+	fsys, err := Open(pathOrName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return Extend(fsys, location(pathOrName))
+}
+
+// follow the extends chain of the given theme. Relative paths in its
+// theme.yaml are resolved against dir. If dir is empty, only names of bundled
+// themes and absolute paths can be extended
+func Extend(fsys fs.FS, dir string) (fs.FS, []string, error) {
+	layers := []fs.FS{}
+	dirs := []string{}
+	seen := map[string]bool{}
+
+	for i := 0; ; i++ {
+		if i == maxExtends {
+			return nil, nil, fmt.Errorf("theme extends more than %d levels deep", maxExtends)
+		}
+
+		layers = append(layers, fsys)
+		if dir != "" {
+			if info, err := os.Stat(dir); err == nil && info.IsDir() {
+				dirs = append(dirs, dir)
+			}
+		}
+
+		c, err := readConfig(fsys)
+		if err != nil {
+			return nil, nil, err
+		}
+		if c.Extends == "" {
+			break
+		}
+
+		// prefer a path relative to the theme, if it exists, and fall back to
+		// the name of a bundled theme
+		parent := c.Extends
+		if dir != "" && !filepath.IsAbs(parent) {
+			if _, err := os.Stat(filepath.Join(dir, parent)); err == nil {
+				parent = filepath.Join(dir, parent)
+			}
+		}
+		if seen[parent] {
+			return nil, nil, fmt.Errorf("theme %q extends itself", parent)
+		}
+		seen[parent] = true
+
+		fsys, err = Open(parent)
+		if err != nil {
+			return nil, nil, fmt.Errorf("extend: %w", err)
+		}
+		dir = location(parent)
+	}
+
+	return Layers(layers...), dirs, nil
+}
+
+func readConfig(fsys fs.FS) (Config, error) {
+	var c Config
+	b, err := fs.ReadFile(fsys, ConfigName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	if err := yaml.UnmarshalStrict(b, &c); err != nil {
+		return c, fmt.Errorf("parse %s: %w", ConfigName, err)
+	}
+	return c, nil
+}
+
+// the dir, relative paths in the theme.yaml of the given theme are resolved
+// against. For dirs that is the theme dir itself, for archives the dir
+// containing the archive. Bundled themes have no location
+func location(pathOrName string) string {
+	info, err := os.Stat(pathOrName)
+	if err != nil {
+		return ""
+	}
+	if info.IsDir() {
+		return pathOrName
+	}
+	return filepath.Dir(pathOrName)
+}
+
+// open the theme by path or name. The path may point to a directory, or to a
+// zip or tar archive, optionally gzip compressed. If nothing exists at the
+// given path, the bundled theme of that name is used
+func Open(pathOrName string) (fs.FS, error) {
+	if pathOrName == "" {
+		pathOrName = DefaultName
+	}
+
+	info, err := os.Stat(pathOrName)
+	if errors.Is(err, fs.ErrNotExist) {
+		if strings.ContainsRune(pathOrName, filepath.Separator) || strings.ContainsRune(pathOrName, '/') {
+			return nil, fmt.Errorf("theme %q does not exist", pathOrName)
+		}
+		return Builtin(pathOrName)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return os.DirFS(pathOrName), nil
+	}
+
+	var fsys fs.FS
+	switch name := strings.ToLower(pathOrName); {
+	case strings.HasSuffix(name, ".zip"):
+		fsys, err = openZip(pathOrName)
+	case strings.HasSuffix(name, ".tar"):
+		fsys, err = openTar(pathOrName, false)
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		fsys, err = openTar(pathOrName, true)
+	default:
+		return nil, fmt.Errorf("theme %q: unsupported archive format", pathOrName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("theme %q: %w", pathOrName, err)
+	}
+
+	return unwrapRoot(fsys)
+}
+
+func openZip(path string) (fs.FS, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return zip.NewReader(bytes.NewReader(b), int64(len(b)))
+}
+
+// tar archives don't allow random access, so they are converted to a zip
+// archive in memory, which implements fs.FS already
+func openTar(path string, gzipped bool) (fs.FS, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if gzipped {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	// the entries are stored uncompressed, since the archive only lives in
+	// memory for the duration of the build
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := strings.Trim(pathpkg.Clean(h.Name), "/")
+		if name == "." || !fs.ValidPath(name) {
+			continue
+		}
+		switch h.Typeflag {
+		case tar.TypeDir:
+			zh := &zip.FileHeader{Name: name + "/", Method: zip.Store, Modified: h.ModTime}
+			zh.SetMode(fs.ModeDir | 0755)
+			if _, err := zw.CreateHeader(zh); err != nil {
+				return nil, err
+			}
+		case tar.TypeReg:
+			zh := &zip.FileHeader{Name: name, Method: zip.Store, Modified: h.ModTime}
+			zh.SetMode(0644)
+			w, err := zw.CreateHeader(zh)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := io.Copy(w, tr); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+}
+
+// archives often contain a single top level dir, i.e. when they have been
+// downloaded from a git forge. In that case, the theme is the content of it
+func unwrapRoot(fsys fs.FS) (fs.FS, error) {
+	if _, err := fs.Stat(fsys, "templates"); err == nil {
+		return fsys, nil
+	}
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		return fs.Sub(fsys, entries[0].Name())
+	}
+	return fsys, nil
+}